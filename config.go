@@ -2,19 +2,69 @@ package main
 
 import (
 	"bufio"
+	"encoding/base64"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"tg-business-bot/internal/encryption"
+	"time"
 )
 
 type Config struct {
 	BotToken           string
+	StorageDriver      string
+	DatabaseURL        string
 	SupabaseURL        string
 	SupabaseServiceKey string
 	GroqMasterKey      string
+	// SharedKeyQuota membatasi balasan per owner per hari dengan GroqMasterKey (0 = tanpa batas)
+	SharedKeyQuota     int
+	// ModelPricing adalah harga per 1 juta token: "model=input/output,..." ("*" untuk model lain)
+	ModelPricing       string
+	// HistoryMaxTokens membatasi konteks per balasan (0 = seluruh context window model)
+	HistoryMaxTokens   int
+	ReplyReserveTokens int
+	// SummaryThreshold: jumlah pesan belum diringkas yang memicu pembaruan ringkasan (0 = nonaktif)
+	SummaryThreshold   int
+	SummaryKeepRecent  int
+	// StreamReplies menampilkan balasan bertahap, diedit setiap StreamEditInterval
+	StreamReplies      bool
+	StreamEditInterval time.Duration
+	// KnowledgeMaxTokens: anggaran token potongan knowledge base per balasan (0 = nonaktif)
+	KnowledgeMaxTokens int
+	// TakeoverCooldown: lama AI dijeda untuk pelanggan setelah owner membalas sendiri
+	TakeoverCooldown   time.Duration
+	LocalAIURL         string // Server Ollama lokal milik operator (opsional)
+	// KeyProvider menentukan asal master key: env, file, atau kms-local (envelope encryption)
+	KeyProvider        string
 	EncryptionKey      string
+	EncryptionKeyFile  string
+	// EncryptionKDF kosong berarti otomatis: secret 32 karakter dipakai mentah, selain itu Argon2id
+	EncryptionKDF      string
+	KMSMasterKey       string
+	WrappedDataKey     string
+	// EncryptionKeySalt adalah salt acak per deployment untuk Argon2id (base64, dari "generate-key-salt")
+	EncryptionKeySalt  []byte
+	// EncryptionKeyID menandai ciphertext baru; EncryptionOldKeys ("id:key,...") hanya untuk dekripsi selama rotasi
+	EncryptionKeyID    string
+	EncryptionOldKeys  string
+	// AllowUnbound mengizinkan ciphertext lama yang belum terikat ke pemiliknya; hanya untuk masa migrasi
+	AllowUnbound       bool
 	Port               string
 	Debug              bool
+
+	// Mode penerimaan update: "polling" (default, untuk development lokal) atau "webhook".
+	UpdateMode    string
+	WebhookURL    string
+	WebhookSecret string
+
+	// Worker pool untuk memproses update secara paralel per percakapan.
+	WorkerCount     int
+	WorkerQueueSize int
+
+	// Interval pengecekan ulang API key owner ke provider
+	KeyCheckInterval time.Duration
 }
 
 func loadEnvFile() {
@@ -41,27 +91,150 @@ func loadEnvFile() {
 	}
 }
 
+func getEnvInt(key string, def int) int {
+	return getEnvIntMin(key, def, 1)
+}
+
+// getEnvIntMin membaca bilangan bulat >= min; dipakai dengan min 0 untuk setting yang
+// memakai 0 sebagai "tanpa batas" atau "nonaktif".
+func getEnvIntMin(key string, def, min int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < min {
+		log.Printf("Warning: Invalid value for %s (%q), using default %d", key, val, def)
+		return def
+	}
+	return n
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: Invalid value for %s (%q), using default %s", key, val, def)
+		return def
+	}
+	return d
+}
+
 func LoadConfig() *Config {
 	loadEnvFile()
 
 	conf := &Config{
 		BotToken:           os.Getenv("BOT_TOKEN"),
+		StorageDriver:      os.Getenv("STORAGE_DRIVER"),
+		DatabaseURL:        os.Getenv("DATABASE_URL"),
 		SupabaseURL:        os.Getenv("SUPABASE_URL"),
 		SupabaseServiceKey: os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
 		GroqMasterKey:      os.Getenv("GROQ_API_KEY"),
+		LocalAIURL:         os.Getenv("LOCAL_AI_URL"),
+		KeyProvider:        os.Getenv("ENCRYPTION_KEY_PROVIDER"),
 		EncryptionKey:      os.Getenv("ENCRYPTION_KEY"),
+		EncryptionKeyFile:  os.Getenv("ENCRYPTION_KEY_FILE"),
+		EncryptionKDF:      os.Getenv("ENCRYPTION_KDF"),
+		KMSMasterKey:       os.Getenv("ENCRYPTION_KMS_MASTER_KEY"),
+		WrappedDataKey:     os.Getenv("ENCRYPTION_WRAPPED_KEY"),
+		EncryptionKeyID:    os.Getenv("ENCRYPTION_KEY_ID"),
+		EncryptionOldKeys:  os.Getenv("ENCRYPTION_OLD_KEYS"),
+		AllowUnbound:       os.Getenv("ENCRYPTION_ALLOW_UNBOUND") == "true",
 		Port:               os.Getenv("PORT"),
 		Debug:              os.Getenv("DEBUG") == "true",
+		UpdateMode:         os.Getenv("UPDATE_MODE"),
+		WebhookURL:         os.Getenv("WEBHOOK_URL"),
+		WebhookSecret:      os.Getenv("WEBHOOK_SECRET"),
+		WorkerCount:        getEnvInt("WORKER_COUNT", 8),
+		WorkerQueueSize:    getEnvInt("WORKER_QUEUE_SIZE", 100),
+		SharedKeyQuota:     getEnvIntMin("SHARED_KEY_DAILY_QUOTA", 50, 0),
+		ModelPricing:       os.Getenv("MODEL_PRICING"),
+		HistoryMaxTokens:   getEnvIntMin("HISTORY_MAX_TOKENS", 8000, 0),
+		ReplyReserveTokens: getEnvInt("REPLY_RESERVE_TOKENS", 1024),
+		SummaryThreshold:   getEnvIntMin("SUMMARY_THRESHOLD", 30, 0),
+		SummaryKeepRecent:  getEnvInt("SUMMARY_KEEP_RECENT", 10),
+		StreamReplies:      os.Getenv("STREAM_REPLIES") != "false",
+		StreamEditInterval: getEnvDuration("STREAM_EDIT_INTERVAL", 1500*time.Millisecond),
+		KnowledgeMaxTokens: getEnvIntMin("KNOWLEDGE_MAX_TOKENS", 1000, 0),
+		TakeoverCooldown:   getEnvDuration("OWNER_TAKEOVER_COOLDOWN", 30*time.Minute),
+		KeyCheckInterval:   getEnvDuration("KEY_CHECK_INTERVAL", 6*time.Hour),
+	}
+
+	if conf.Port == "" {
+		conf.Port = "8080"
+	}
+	if conf.UpdateMode == "" {
+		conf.UpdateMode = "polling"
+	}
+	if conf.StorageDriver == "" {
+		conf.StorageDriver = "supabase"
+	}
+	if conf.KeyProvider == "" {
+		conf.KeyProvider = "env"
+	}
+	if conf.EncryptionKeyID == "" {
+		conf.EncryptionKeyID = "k1"
+	}
+
+	if conf.BotToken == "" {
+		log.Fatalf("Critical Error: Missing required variables in .env. Check BOT_TOKEN")
+	}
+
+	switch conf.StorageDriver {
+	case "supabase":
+		if conf.SupabaseURL == "" {
+			log.Fatalf("Critical Error: STORAGE_DRIVER=supabase requires SUPABASE_URL")
+		}
+	case "postgres", "sqlite":
+		if conf.DatabaseURL == "" {
+			log.Fatalf("Critical Error: STORAGE_DRIVER=%s requires DATABASE_URL", conf.StorageDriver)
+		}
+	case "memory":
+		log.Printf("Warning: STORAGE_DRIVER=memory keeps all data in RAM; everything is lost on restart")
+	default:
+		log.Fatalf("Critical Error: STORAGE_DRIVER must be one of supabase, postgres, sqlite or memory. Current value: %s", conf.StorageDriver)
+	}
+
+	switch conf.KeyProvider {
+	case "env":
+		if conf.EncryptionKey == "" {
+			log.Fatalf("Critical Error: ENCRYPTION_KEY_PROVIDER=env requires ENCRYPTION_KEY")
+		}
+	case "file":
+		if conf.EncryptionKeyFile == "" {
+			log.Fatalf("Critical Error: ENCRYPTION_KEY_PROVIDER=file requires ENCRYPTION_KEY_FILE")
+		}
+	case "kms-local":
+		if conf.KMSMasterKey == "" {
+			log.Fatalf("Critical Error: ENCRYPTION_KEY_PROVIDER=kms-local requires ENCRYPTION_KMS_MASTER_KEY")
+		}
+	default:
+		log.Fatalf("Critical Error: ENCRYPTION_KEY_PROVIDER must be one of env, file or kms-local. Current value: %s", conf.KeyProvider)
+	}
+
+	if raw := os.Getenv("ENCRYPTION_KEY_SALT"); raw != "" {
+		salt, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil || len(salt) < encryption.MinSaltSize {
+			log.Fatalf("Critical Error: ENCRYPTION_KEY_SALT must be base64 of at least %d random bytes; generate one with \"generate-key-salt\"", encryption.MinSaltSize)
+		}
+		conf.EncryptionKeySalt = salt
+	}
+
+	if conf.EncryptionKDF != "" && conf.EncryptionKDF != encryption.KDFArgon2 && conf.EncryptionKDF != encryption.KDFNone {
+		log.Fatalf("Critical Error: ENCRYPTION_KDF must be either '%s' or '%s'. Current value: %s", encryption.KDFArgon2, encryption.KDFNone, conf.EncryptionKDF)
 	}
 
-	if conf.BotToken == "" || conf.EncryptionKey == "" || conf.SupabaseURL == "" {
-		log.Fatalf("Critical Error: Missing required variables in .env. Check BOT_TOKEN, ENCRYPTION_KEY, and SUPABASE_URL")
+	if conf.UpdateMode != "polling" && conf.UpdateMode != "webhook" {
+		log.Fatalf("Critical Error: UPDATE_MODE must be either 'polling' or 'webhook'. Current value: %s", conf.UpdateMode)
 	}
 
-	if len(conf.EncryptionKey) != 32 {
-		log.Fatalf("Critical Error: ENCRYPTION_KEY must be exactly 32 characters. Current length: %d", len(conf.EncryptionKey))
+	if conf.UpdateMode == "webhook" && (conf.WebhookURL == "" || conf.WebhookSecret == "") {
+		log.Fatalf("Critical Error: UPDATE_MODE=webhook requires WEBHOOK_URL and WEBHOOK_SECRET")
 	}
 
-	log.Printf("System: Configuration loaded successfully. Debug: %v", conf.Debug)
+	log.Printf("System: Configuration loaded successfully. Debug: %v, Mode: %s, Storage: %s, Workers: %d", conf.Debug, conf.UpdateMode, conf.StorageDriver, conf.WorkerCount)
 	return conf
 }
\ No newline at end of file
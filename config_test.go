@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetEnvIntMin(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		min   int
+		want  int
+	}{
+		{"unset uses default", "", 1, 7},
+		{"valid", "25", 1, 25},
+		{"at minimum", "1", 1, 1},
+		{"below minimum", "0", 1, 7},
+		{"zero allowed", "0", 0, 0},
+		{"negative", "-3", 0, 7},
+		{"not a number", "ten", 0, 7},
+		{"fraction", "2.5", 0, 7},
+		{"overflow", "99999999999999999999", 0, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_ENV_INT", tt.value)
+			if got := getEnvIntMin("TEST_ENV_INT", 7, tt.min); got != tt.want {
+				t.Errorf("getEnvIntMin(%q, min %d) = %d, want %d", tt.value, tt.min, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvIntRejectsZero(t *testing.T) {
+	t.Setenv("TEST_ENV_INT", "0")
+	if got := getEnvInt("TEST_ENV_INT", 7); got != 7 {
+		t.Errorf("getEnvInt(\"0\") = %d, want the default 7", got)
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", time.Minute},
+		{"90s", 90 * time.Second},
+		{"0s", time.Minute},
+		{"-5m", time.Minute},
+		{"5", time.Minute},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_ENV_DURATION", tt.value)
+		if got := getEnvDuration("TEST_ENV_DURATION", time.Minute); got != tt.want {
+			t.Errorf("getEnvDuration(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,3 +1,14 @@
 module tg-business-bot
 
 go 1.20
+
+require (
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+)
+
+require (
+	golang.org/x/crypto v0.17.0
+	golang.org/x/sys v0.15.0 // indirect
+)
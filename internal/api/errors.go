@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Jenis kegagalan saat memanggil provider AI. Gunakan errors.Is untuk memeriksanya.
+var (
+	ErrUnauthorized = errors.New("api key rejected")
+	ErrForbidden    = errors.New("api key not permitted")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrNetwork      = errors.New("network error")
+	ErrProvider     = errors.New("provider error")
+)
+
+// ProviderError membawa status HTTP dan isi respons dari provider AI.
+type ProviderError struct {
+	Kind   error
+	Status int
+	Body   string
+}
+
+func (e *ProviderError) Error() string {
+	if e.Status == 0 {
+		return fmt.Sprintf("AI Provider Error: %v: %s", e.Kind, e.Body)
+	}
+	return fmt.Sprintf("AI Provider Error: %v (status %d): %s", e.Kind, e.Status, e.Body)
+}
+
+func (e *ProviderError) Is(target error) bool { return target == e.Kind }
+
+// statusError mengklasifikasikan respons non-200 dari provider.
+func statusError(status int, body []byte) error {
+	kind := ErrProvider
+	switch status {
+	case 401:
+		kind = ErrUnauthorized
+	case 403:
+		kind = ErrForbidden
+	case 429:
+		kind = ErrRateLimited
+	}
+	return &ProviderError{Kind: kind, Status: status, Body: string(body)}
+}
+
+func networkError(err error) error {
+	return &ProviderError{Kind: ErrNetwork, Body: err.Error()}
+}
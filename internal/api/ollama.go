@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+var _ ChatProvider = (*OllamaClient)(nil)
+
+// OllamaClient memakai API native Ollama (/api/chat) pada server lokal milik operator.
+type OllamaClient struct {
+	BaseURL string
+	client  *http.Client
+}
+
+func NewOllamaClient(baseURL string) *OllamaClient {
+	return &OllamaClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		// Model lokal bisa lambat saat pertama kali dimuat ke memori
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (c *OllamaClient) GetChatCompletion(model string, history []models.ChatMessage) (*Completion, error) {
+	url := c.BaseURL + "/api/chat"
+
+	payload := map[string]interface{}{
+		"model":    model,
+		"messages": history,
+		"stream":   false,
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		// Ollama melaporkan token prompt dan balasan sebagai jumlah evaluasi
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
+	}
+	json.Unmarshal(body, &result)
+	if result.Message.Content == "" {
+		return nil, fmt.Errorf("empty response")
+	}
+	return &Completion{
+		Content: result.Message.Content,
+		Usage:   TokenUsage{PromptTokens: result.PromptEvalCount, CompletionTokens: result.EvalCount},
+	}, nil
+}
+
+// ListModels membaca model yang sudah di-pull ke server Ollama (/api/tags).
+func (c *OllamaClient) ListModels() ([]ModelInfo, error) {
+	resp, err := c.client.Get(c.BaseURL + "/api/tags")
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	var list []ModelInfo
+	for _, m := range result.Models {
+		list = append(list, ModelInfo{ID: m.Name, OwnedBy: "local"})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
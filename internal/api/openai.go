@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+var _ ChatProvider = (*OpenAIClient)(nil)
+
+// OpenAIClient berbicara dengan endpoint apa pun yang kompatibel dengan OpenAI
+// (/chat/completions), misalnya Groq, OpenRouter, Together atau server llama.cpp.
+type OpenAIClient struct {
+	BaseURL string
+	APIKey  string
+	client  *http.Client
+}
+
+func NewOpenAIClient(baseURL, apiKey string) *OpenAIClient {
+	return &OpenAIClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		client:  newPublicHTTPClient(120 * time.Second),
+	}
+}
+
+func (c *OpenAIClient) GetChatCompletion(model string, history []models.ChatMessage) (*Completion, error) {
+	url := c.BaseURL + "/chat/completions"
+
+	payload := map[string]interface{}{
+		"model":    model,
+		"messages": history,
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+
+	json.Unmarshal(body, &result)
+	if len(result.Choices) > 0 {
+		return &Completion{
+			Content: result.Choices[0].Message.Content,
+			Usage:   TokenUsage{PromptTokens: result.Usage.PromptTokens, CompletionTokens: result.Usage.CompletionTokens},
+		}, nil
+	}
+	return nil, fmt.Errorf("empty response")
+}
+
+// ListModels membaca katalog /models. Groq menambahkan field active dan context_window,
+// OpenRouter memakai context_length; keduanya dibaca jika ada.
+func (c *OpenAIClient) ListModels() ([]ModelInfo, error) {
+	req, _ := http.NewRequest("GET", c.BaseURL+"/models", nil)
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Data []struct {
+			ID            string `json:"id"`
+			OwnedBy       string `json:"owned_by"`
+			Active        *bool  `json:"active"`
+			ContextWindow int    `json:"context_window"`
+			ContextLength int    `json:"context_length"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	var list []ModelInfo
+	for _, m := range result.Data {
+		if m.Active != nil && !*m.Active {
+			continue
+		}
+		ctx := m.ContextWindow
+		if ctx == 0 {
+			ctx = m.ContextLength
+		}
+		list = append(list, ModelInfo{ID: m.ID, OwnedBy: m.OwnedBy, ContextWindow: ctx})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"tg-business-bot/internal/models"
+)
+
+// Nama provider AI yang bisa dipilih owner di dashboard.
+const (
+	ProviderGroq   = "groq"
+	ProviderOpenAI = "openai"
+	ProviderLocal  = "local"
+)
+
+// ChatProvider adalah backend AI yang menghasilkan balasan dari riwayat percakapan.
+type ChatProvider interface {
+	GetChatCompletion(model string, history []models.ChatMessage) (*Completion, error)
+	// ListModels mengembalikan model yang saat ini bisa dipakai, sudah tanpa model yang dipensiunkan.
+	ListModels() ([]ModelInfo, error)
+}
+
+// Completion adalah balasan AI beserta jumlah token yang dilaporkan provider.
+type Completion struct {
+	Content string
+	Usage   TokenUsage
+}
+
+// TokenUsage bernilai 0 jika provider tidak melaporkan pemakaian token.
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ModelInfo menjelaskan satu model dari katalog provider. ContextWindow bernilai 0 jika
+// provider tidak melaporkannya.
+type ModelInfo struct {
+	ID            string
+	OwnedBy       string
+	ContextWindow int
+}
+
+// NewChatProvider membuat klien untuk provider pilihan owner. baseURL dipakai oleh provider
+// "openai" (endpoint milik owner) dan "local" (server Ollama milik operator).
+func NewChatProvider(provider, baseURL, apiKey string) (ChatProvider, error) {
+	switch provider {
+	case ProviderGroq, "":
+		return NewGroqClient(apiKey), nil
+	case ProviderOpenAI:
+		if baseURL == "" {
+			return nil, fmt.Errorf("provider %s requires a base URL", provider)
+		}
+		return NewOpenAIClient(baseURL, apiKey), nil
+	case ProviderLocal:
+		if baseURL == "" {
+			return nil, fmt.Errorf("local AI server is not configured")
+		}
+		return NewOllamaClient(baseURL), nil
+	}
+	return nil, fmt.Errorf("unknown AI provider: %s", provider)
+}
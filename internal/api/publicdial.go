@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress dikembalikan jika endpoint milik owner mengarah ke jaringan internal.
+var ErrPrivateAddress = errors.New("address is not publicly routable")
+
+// cgnatRange (100.64.0.0/10) tidak dianggap privat oleh net.IP.IsPrivate, tetapi juga bukan alamat publik.
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP bernilai false untuk alamat loopback, privat, link-local, multicast dan unspecified.
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || cgnatRange.Contains(ip))
+}
+
+// CheckPublicHost me-resolve host dan menolaknya jika salah satu alamatnya bukan alamat publik.
+// Ini hanya untuk memberi tahu owner lebih awal; yang benar-benar mencegah koneksi adalah
+// publicOnlyControl, karena DNS bisa menjawab berbeda saat koneksi dibuat.
+func CheckPublicHost(host string) error {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("%s resolves to %s: %w", host, ip, ErrPrivateAddress)
+		}
+	}
+	return nil
+}
+
+// publicOnlyControl dipanggil dialer setelah DNS di-resolve dan sebelum koneksi dibuka, jadi
+// host yang di-resolve ulang ke alamat internal (DNS rebinding) atau redirect ke sana juga ditolak.
+func publicOnlyControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("dial %s: %w", address, ErrPrivateAddress)
+	}
+	return nil
+}
+
+// newPublicHTTPClient membuat klien untuk endpoint yang URL-nya diisi owner. Klien ini hanya
+// bisa terhubung ke alamat publik dan tidak memakai proxy dari environment, karena koneksi ke
+// proxy akan melewati pengecekan alamat tujuan.
+func newPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicOnlyControl,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
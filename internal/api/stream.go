@@ -0,0 +1,253 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// StreamingProvider adalah ChatProvider yang bisa mengirim balasan sedikit demi sedikit.
+// onDelta dipanggil untuk setiap potongan teks baru secara berurutan; Completion yang
+// dikembalikan berisi teks lengkap dan pemakaian token.
+type StreamingProvider interface {
+	ChatProvider
+	StreamChatCompletion(model string, history []models.ChatMessage, onDelta func(string)) (*Completion, error)
+}
+
+var (
+	_ StreamingProvider = (*OpenAIClient)(nil)
+	_ StreamingProvider = (*OllamaClient)(nil)
+)
+
+// maxStreamLine membatasi panjang satu baris event stream.
+const maxStreamLine = 1 << 20
+
+// Batas waktu streaming. Timeout milik http.Client tidak dipakai karena batas itu ikut memotong
+// body yang masih mengalir; sebagai gantinya stream diputus jika header tidak datang dalam batas
+// header, jika tidak ada data baru selama streamIdleTimeout, atau setelah streamMaxDuration.
+const (
+	streamMaxDuration = 10 * time.Minute
+	streamIdleTimeout = 60 * time.Second
+)
+
+var (
+	errStreamHeaderTimeout = errors.New("stream timed out waiting for response headers")
+	errStreamStalled       = errors.New("stream stalled: no data received")
+)
+
+// streamBody memperpanjang batas diam setiap kali ada data masuk dan membatalkan request saat
+// ditutup.
+type streamBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	idle   time.Duration
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.idle)
+	}
+	if err != nil && err != io.EOF && b.ctx.Err() != nil {
+		err = context.Cause(b.ctx)
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// doStream mengirim request streaming dengan context per request: batas total streamMaxDuration,
+// headerTimeout untuk menunggu header, lalu idleTimeout antar potongan data. Body respons harus
+// ditutup pemanggil.
+func doStream(client *http.Client, req *http.Request, headerTimeout, idleTimeout time.Duration) (*http.Response, error) {
+	ctx, cancelCause := context.WithCancelCause(req.Context())
+	ctx, cancelTimeout := context.WithTimeout(ctx, streamMaxDuration)
+	cancel := func() {
+		cancelTimeout()
+		cancelCause(context.Canceled)
+	}
+
+	timer := time.AfterFunc(headerTimeout, func() { cancelCause(errStreamHeaderTimeout) })
+	streaming := *client
+	streaming.Timeout = 0
+	resp, err := streaming.Do(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
+		cancel()
+		return nil, err
+	}
+
+	// Setelah header diterima, timer yang sama menjaga jeda antar potongan data
+	timer.Stop()
+	timer = time.AfterFunc(idleTimeout, func() { cancelCause(errStreamStalled) })
+	resp.Body = &streamBody{ReadCloser: resp.Body, ctx: ctx, idle: idleTimeout, timer: timer, cancel: cancel}
+	return resp, nil
+}
+
+// StreamChatCompletion memakai Server-Sent Events dari /chat/completions (stream: true).
+// Pemakaian token dibaca dari chunk terakhir (usage) atau dari x_groq.usage milik Groq.
+func (c *OpenAIClient) StreamChatCompletion(model string, history []models.ChatMessage, onDelta func(string)) (*Completion, error) {
+	payload := map[string]interface{}{
+		"model":          model,
+		"messages":       history,
+		"stream":         true,
+		"stream_options": map[string]interface{}{"include_usage": true},
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", c.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := doStream(c.client, req, streamIdleTimeout, streamIdleTimeout)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	type usageBlock struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	}
+	var content strings.Builder
+	var usage TokenUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			// Baris kosong, komentar (": ping") dan field event lain diabaikan
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *usageBlock `json:"usage"`
+			XGroq *struct {
+				Usage *usageBlock `json:"usage"`
+			} `json:"x_groq"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != nil {
+			return nil, &ProviderError{Kind: ErrProvider, Body: chunk.Error.Message}
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onDelta(choice.Delta.Content)
+			}
+		}
+		if u := chunk.Usage; u != nil {
+			usage = TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+		} else if chunk.XGroq != nil && chunk.XGroq.Usage != nil {
+			u := chunk.XGroq.Usage
+			usage = TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, networkError(err)
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+	return &Completion{Content: content.String(), Usage: usage}, nil
+}
+
+// StreamChatCompletion memakai stream NDJSON dari /api/chat milik Ollama: satu objek JSON per
+// baris, objek terakhir (done: true) membawa jumlah token.
+func (c *OllamaClient) StreamChatCompletion(model string, history []models.ChatMessage, onDelta func(string)) (*Completion, error) {
+	payload := map[string]interface{}{
+		"model":    model,
+		"messages": history,
+		"stream":   true,
+	}
+
+	jsonData, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", c.BaseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Model lokal bisa lambat saat pertama kali dimuat ke memori, jadi header boleh lama datang
+	resp, err := doStream(c.client, req, c.client.Timeout, streamIdleTimeout)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, statusError(resp.StatusCode, body)
+	}
+
+	var content strings.Builder
+	var usage TokenUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		var chunk struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Done            bool   `json:"done"`
+			Error           string `json:"error"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			return nil, &ProviderError{Kind: ErrProvider, Body: chunk.Error}
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onDelta(chunk.Message.Content)
+		}
+		if chunk.Done {
+			usage = TokenUsage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, networkError(err)
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+	return &Completion{Content: content.String(), Usage: usage}, nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoStreamOutlivesClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 6; i++ {
+			io.WriteString(w, "data: x\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	// Timeout klien (100ms) lebih pendek dari stream (~180ms); selama data terus mengalir stream
+	// tidak boleh terpotong
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := doStream(client, req, time.Second, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Count(string(body), "data: x") != 6 {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestDoStreamHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	_, err := doStream(srv.Client(), req, 50*time.Millisecond, time.Second)
+	if !errors.Is(err, errStreamHeaderTimeout) {
+		t.Fatalf("err = %v, want errStreamHeaderTimeout", err)
+	}
+}
+
+func TestDoStreamIdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "data: x\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := doStream(srv.Client(), req, time.Second, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, errStreamStalled) {
+		t.Fatalf("err = %v, want errStreamStalled", err)
+	}
+}
@@ -2,14 +2,24 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"net/http"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
 )
 
 type TelegramClient struct {
 	Token   string
 	BaseURL string
+	// FileURL adalah awalan URL unduhan file (https://api.telegram.org/file/bot<token>)
+	FileURL string
+	client  *http.Client
 }
 
 type Update struct {
@@ -21,9 +31,29 @@ type Update struct {
 }
 
 type BusinessConnection struct {
-	ID         string `json:"id"`
-	UserChatID int64  `json:"user_chat_id"`
-	IsEnabled  bool   `json:"is_enabled"`
+	ID         string             `json:"id"`
+	User       *User              `json:"user"`
+	UserChatID int64              `json:"user_chat_id"`
+	Date       int64              `json:"date"`
+	CanReply   bool               `json:"can_reply"` // Field lama (sebelum Bot API 9.0)
+	Rights     *BusinessBotRights `json:"rights"`
+	IsEnabled  bool               `json:"is_enabled"`
+}
+
+// BusinessBotRights adalah hak yang diberikan owner kepada bot (Bot API 9.0+).
+type BusinessBotRights struct {
+	CanReply              bool `json:"can_reply"`
+	CanReadMessages       bool `json:"can_read_messages"`
+	CanDeleteSentMessages bool `json:"can_delete_sent_messages"`
+	CanDeleteAllMessages  bool `json:"can_delete_all_messages"`
+}
+
+// CanBotReply menggabungkan field lama can_reply dan objek rights yang baru.
+func (c *BusinessConnection) CanBotReply() bool {
+	if c.Rights != nil {
+		return c.Rights.CanReply
+	}
+	return c.CanReply
 }
 
 type Message struct {
@@ -33,6 +63,24 @@ type Message struct {
 	Text                 string `json:"text"`
 	BusinessConnectionID string `json:"business_connection_id"`
 	Location             *Location `json:"location"` // <-- TAMBAHKAN INI
+	Document             *Document `json:"document"`
+	// SenderBusinessBot terisi jika pesan bisnis dikirim bot atas nama owner
+	SenderBusinessBot    *User     `json:"sender_business_bot"`
+}
+
+// Document adalah file umum yang dikirim sebagai dokumen (bukan foto atau video).
+type Document struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	FileSize int64  `json:"file_size"`
+}
+
+// File adalah hasil getFile; FilePath dipakai untuk mengunduh isinya.
+type File struct {
+	FileID   string `json:"file_id"`
+	FileSize int64  `json:"file_size"`
+	FilePath string `json:"file_path"`
 }
 
 type Location struct {
@@ -59,29 +107,45 @@ type CallbackQuery struct {
 	Msg  *Message `json:"message"`
 }
 
+// Batas waktu dan percobaan ulang untuk request ke Bot API.
+const (
+	telegramRequestTimeout = 15 * time.Second
+	telegramMaxAttempts    = 3
+	telegramRetryBackoff   = 500 * time.Millisecond
+	// telegramMaxFloodWait: jeda 429 yang lebih lama dari ini tidak ditunggu, error langsung dikembalikan
+	telegramMaxFloodWait = 30 * time.Second
+)
+
 func NewTelegramClient(token string) *TelegramClient {
 	return &TelegramClient{
 		Token:   token,
 		BaseURL: "https://api.telegram.org/bot" + token,
+		FileURL: "https://api.telegram.org/file/bot" + token,
+		// Satu client untuk semua request supaya koneksi ke Telegram dipakai ulang. Batas
+		// waktu total diatur per request lewat context (long polling butuh waktu lebih lama).
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				DialContext:         (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
 	}
 }
 
-func (t *TelegramClient) AnswerCallback(callbackQueryID string) {
-	url := t.BaseURL + "/answerCallbackQuery"
-	payload := map[string]interface{}{"callback_query_id": callbackQueryID}
-	jsonData, _ := json.Marshal(payload)
-	http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+func (t *TelegramClient) AnswerCallback(callbackQueryID string) error {
+	return t.call("answerCallbackQuery", map[string]interface{}{"callback_query_id": callbackQueryID}, nil)
 }
 
-func (t *TelegramClient) DeleteMessage(chatID int64, messageID int64) {
-	url := t.BaseURL + "/deleteMessage"
-	payload := map[string]interface{}{"chat_id": chatID, "message_id": messageID}
-	jsonData, _ := json.Marshal(payload)
-	http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+func (t *TelegramClient) DeleteMessage(chatID int64, messageID int64) error {
+	return t.call("deleteMessage", map[string]interface{}{"chat_id": chatID, "message_id": messageID}, nil)
 }
 
+// SendMessage mengirim pesan HTML (dengan keyboard jika markup tidak nil) dan mengembalikan
+// ID pesannya.
 func (t *TelegramClient) SendMessage(chatID int64, text string, businessConnID string, markup interface{}) (int64, error) {
-	url := t.BaseURL + "/sendMessage"
 	payload := map[string]interface{}{
 		"chat_id":    chatID,
 		"text":       text,
@@ -93,23 +157,17 @@ func (t *TelegramClient) SendMessage(chatID int64, text string, businessConnID s
 	if markup != nil {
 		payload["reply_markup"] = markup
 	}
-	jsonData, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
 	var res struct {
-		Result struct {
-			MessageID int64 `json:"message_id"`
-		} `json:"result"`
+		MessageID int64 `json:"message_id"`
 	}
-	json.NewDecoder(resp.Body).Decode(&res)
-	return res.Result.MessageID, nil
+	if err := t.call("sendMessage", payload, &res); err != nil {
+		return 0, err
+	}
+	return res.MessageID, nil
 }
 
-func (t *TelegramClient) EditMessage(chatID int64, messageID int64, text string, markup interface{}) {
-	url := t.BaseURL + "/editMessageText"
+// EditMessage mengganti isi dan keyboard pesan HTML milik bot.
+func (t *TelegramClient) EditMessage(chatID int64, messageID int64, text string, markup interface{}) error {
 	payload := map[string]interface{}{
 		"chat_id":      chatID,
 		"message_id":   messageID,
@@ -117,21 +175,228 @@ func (t *TelegramClient) EditMessage(chatID int64, messageID int64, text string,
 		"parse_mode":   "HTML",
 		"reply_markup": markup,
 	}
-	jsonData, _ := json.Marshal(payload)
-	http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	return t.edit(payload)
 }
 
-func (c *TelegramClient) SendLocation(chatID int64, lat, lon float64, businessConnID string) {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendLocation", c.Token)
+func (t *TelegramClient) SendLocation(chatID int64, lat, lon float64, businessConnID string) error {
 	payload := map[string]interface{}{
-		"chat_id":    chatID,
-		"latitude":   lat,
-		"longitude":  lon,
+		"chat_id":   chatID,
+		"latitude":  lat,
+		"longitude": lon,
+	}
+	if businessConnID != "" {
+		payload["business_connection_id"] = businessConnID
+	}
+	return t.call("sendLocation", payload, nil)
+}
+
+// SendChatAction menampilkan status seperti "typing" di chat pelanggan selama ~5 detik.
+func (t *TelegramClient) SendChatAction(chatID int64, action string, businessConnID string) error {
+	payload := map[string]interface{}{"chat_id": chatID, "action": action}
+	if businessConnID != "" {
+		payload["business_connection_id"] = businessConnID
+	}
+	return t.call("sendChatAction", payload, nil)
+}
+
+// SendText mengirim pesan dengan parse mode tertentu ("" untuk teks biasa) dan mengembalikan
+// ID pesannya.
+func (t *TelegramClient) SendText(chatID int64, text, parseMode, businessConnID string) (int64, error) {
+	payload := map[string]interface{}{"chat_id": chatID, "text": text}
+	if parseMode != "" {
+		payload["parse_mode"] = parseMode
 	}
 	if businessConnID != "" {
 		payload["business_connection_id"] = businessConnID
 	}
-	
-	body, _ := json.Marshal(payload)
-	http.Post(url, "application/json", bytes.NewBuffer(body))
-}
\ No newline at end of file
+	var res struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := t.call("sendMessage", payload, &res); err != nil {
+		return 0, err
+	}
+	return res.MessageID, nil
+}
+
+// EditText mengubah isi pesan, termasuk pesan yang dikirim lewat koneksi bisnis.
+func (t *TelegramClient) EditText(chatID, messageID int64, text, parseMode, businessConnID string) error {
+	payload := map[string]interface{}{"chat_id": chatID, "message_id": messageID, "text": text}
+	if parseMode != "" {
+		payload["parse_mode"] = parseMode
+	}
+	if businessConnID != "" {
+		payload["business_connection_id"] = businessConnID
+	}
+	return t.edit(payload)
+}
+
+// edit memanggil editMessageText. Isi yang tidak berubah tidak dianggap gagal.
+func (t *TelegramClient) edit(payload map[string]interface{}) error {
+	err := t.call("editMessageText", payload, nil)
+	if IsNotModified(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteBusinessMessages menghapus pesan yang dikirim lewat koneksi bisnis. Bot butuh hak
+// can_delete_sent_messages (atau can_delete_all_messages) dari owner.
+func (t *TelegramClient) DeleteBusinessMessages(businessConnID string, messageIDs ...int64) error {
+	return t.call("deleteBusinessMessages", map[string]interface{}{"business_connection_id": businessConnID, "message_ids": messageIDs}, nil)
+}
+
+// SetWebhook mendaftarkan URL publik bot ke Telegram. secretToken akan dikirim balik
+// oleh Telegram di header X-Telegram-Bot-Api-Secret-Token pada setiap update.
+func (t *TelegramClient) SetWebhook(webhookURL string, secretToken string) error {
+	payload := map[string]interface{}{
+		"url":             webhookURL,
+		"allowed_updates": []string{"message", "business_connection", "business_message", "callback_query"},
+	}
+	if secretToken != "" {
+		payload["secret_token"] = secretToken
+	}
+	return t.call("setWebhook", payload, nil)
+}
+
+// DeleteWebhook melepas webhook aktif supaya getUpdates (mode polling) bisa dipakai lagi.
+func (t *TelegramClient) DeleteWebhook() error {
+	return t.call("deleteWebhook", map[string]interface{}{"drop_pending_updates": false}, nil)
+}
+
+// GetUpdates mengambil update baru dengan long polling selama paling lama timeout.
+func (t *TelegramClient) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	payload := map[string]interface{}{"offset": offset, "timeout": int(timeout / time.Second)}
+	var updates []Update
+	// Beri kelonggaran di atas timeout long polling sebelum request dianggap macet
+	if err := t.callContext(ctx, "getUpdates", payload, &updates, timeout+telegramRequestTimeout); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// GetFile menyiapkan file untuk diunduh. Bot API hanya melayani file sampai 20 MB.
+func (t *TelegramClient) GetFile(fileID string) (*File, error) {
+	var file File
+	if err := t.call("getFile", map[string]interface{}{"file_id": fileID}, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DownloadFile mengunduh isi file dari FilePath hasil GetFile. File yang lebih besar dari
+// maxBytes ditolak tanpa dibaca seluruhnya.
+func (t *TelegramClient) DownloadFile(filePath string, maxBytes int64) ([]byte, error) {
+	const method = "downloadFile"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*telegramRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", t.FileURL+"/"+filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, &TelegramError{Kind: ErrTelegramNetwork, Method: method, Description: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, telegramError(method, resp.StatusCode, resp.Status, 0)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, &TelegramError{Kind: ErrTelegramNetwork, Method: method, Description: err.Error()}
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%s: file is larger than %d bytes", method, maxBytes)
+	}
+	return data, nil
+}
+
+func (t *TelegramClient) call(method string, payload map[string]interface{}, out interface{}) error {
+	return t.callContext(context.Background(), method, payload, out, telegramRequestTimeout)
+}
+
+// callContext mengirim request ke Bot API dan membaca field result ke out (jika tidak nil).
+// Error 429 ditunggu sesuai retry_after dan error 5xx dicoba lagi dengan backoff, masing-masing
+// sampai telegramMaxAttempts kali. Error jaringan tidak dicoba lagi karena request mungkin
+// sudah diterima Telegram (pesan bisa terkirim dua kali).
+func (t *TelegramClient) callContext(ctx context.Context, method string, payload map[string]interface{}, out interface{}, timeout time.Duration) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	backoff := telegramRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := t.do(ctx, method, body, out, timeout)
+		var te *TelegramError
+		if err == nil || !errors.As(err, &te) || !te.transient() || attempt == telegramMaxAttempts {
+			return err
+		}
+		wait := backoff
+		if te.Kind == ErrTelegramFlood && te.RetryAfter > 0 {
+			wait = time.Duration(te.RetryAfter) * time.Second
+			if wait > telegramMaxFloodWait {
+				return err
+			}
+		}
+		log.Printf("Warning: Telegram %s failed (attempt %d/%d), retrying in %v: %v", method, attempt, telegramMaxAttempts, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// do menjalankan satu request dan membaca amplop ok/error_code/description.
+func (t *TelegramClient) do(ctx context.Context, method string, body []byte, out interface{}, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", t.BaseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// *url.Error memuat URL lengkap yang berisi token bot; jangan sampai masuk log
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return &TelegramError{Kind: ErrTelegramNetwork, Method: method, Description: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		OK          bool            `json:"ok"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		// Respons bukan JSON biasanya datang dari proxy atau gateway di depan Telegram
+		if resp.StatusCode >= 400 {
+			return telegramError(method, resp.StatusCode, "invalid response: "+err.Error(), 0)
+		}
+		return &TelegramError{Kind: ErrTelegramServer, Method: method, Code: resp.StatusCode, Description: "invalid response: " + err.Error()}
+	}
+	if !res.OK {
+		code := res.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return telegramError(method, code, res.Description, res.Parameters.RetryAfter)
+	}
+	if out != nil && len(res.Result) > 0 {
+		return json.Unmarshal(res.Result, out)
+	}
+	return nil
+}
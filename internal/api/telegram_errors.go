@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Jenis kegagalan dari Bot API Telegram. Gunakan errors.Is untuk memeriksanya.
+var (
+	ErrTelegramBadRequest   = errors.New("bad request")
+	ErrTelegramUnauthorized = errors.New("bot token rejected")
+	ErrTelegramForbidden    = errors.New("forbidden")
+	ErrTelegramNotFound     = errors.New("not found")
+	ErrTelegramFlood        = errors.New("too many requests")
+	ErrTelegramServer       = errors.New("server error")
+	ErrTelegramNetwork      = errors.New("network error")
+)
+
+// TelegramError membawa isi amplop ok/error_code/description dari Bot API.
+type TelegramError struct {
+	Kind        error
+	Method      string
+	Code        int
+	Description string
+	// RetryAfter adalah jeda (detik) yang diminta Telegram pada error 429
+	RetryAfter int
+}
+
+func (e *TelegramError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("Telegram Error: %s: %v: %s", e.Method, e.Kind, e.Description)
+	}
+	return fmt.Sprintf("Telegram Error: %s: %v (code %d): %s", e.Method, e.Kind, e.Code, e.Description)
+}
+
+func (e *TelegramError) Is(target error) bool { return target == e.Kind }
+
+// transient menandai error yang layak dicoba lagi dengan request yang sama.
+func (e *TelegramError) transient() bool {
+	return e.Kind == ErrTelegramFlood || e.Kind == ErrTelegramServer
+}
+
+// telegramError mengklasifikasikan respons Bot API dengan ok=false.
+func telegramError(method string, code int, description string, retryAfter int) *TelegramError {
+	kind := ErrTelegramBadRequest
+	switch {
+	case code == 401:
+		kind = ErrTelegramUnauthorized
+	case code == 403:
+		kind = ErrTelegramForbidden
+	case code == 404:
+		kind = ErrTelegramNotFound
+	case code == 429:
+		kind = ErrTelegramFlood
+	case code >= 500:
+		kind = ErrTelegramServer
+	}
+	return &TelegramError{Kind: kind, Method: method, Code: code, Description: description, RetryAfter: retryAfter}
+}
+
+// IsNotModified mengenali error editMessageText saat isi pesan tidak berubah. Error ini
+// tidak berbahaya: pesan sudah menampilkan teks yang diminta.
+func IsNotModified(err error) bool {
+	var te *TelegramError
+	return errors.As(err, &te) && strings.Contains(te.Description, "message is not modified")
+}
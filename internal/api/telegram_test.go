@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testBotToken = "123456:SECRET-token"
+
+// newTestTelegram mengarahkan TelegramClient ke server lokal yang menjawab setiap request
+// dengan respond(nomor request, mulai dari 1).
+func newTestTelegram(t *testing.T, respond func(n int, w http.ResponseWriter)) (*TelegramClient, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/bot"+testBotToken+"/") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		respond(int(atomic.AddInt32(&calls, 1)), w)
+	}))
+	t.Cleanup(srv.Close)
+
+	tg := NewTelegramClient(testBotToken)
+	tg.BaseURL = srv.URL + "/bot" + testBotToken
+	return tg, &calls
+}
+
+func reply(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+func TestTelegramWaitsForFloodRetryAfter(t *testing.T) {
+	tg, calls := newTestTelegram(t, func(n int, w http.ResponseWriter) {
+		if n == 1 {
+			reply(w, 429, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`)
+			return
+		}
+		reply(w, 200, `{"ok":true,"result":{"message_id":42}}`)
+	})
+
+	id, err := tg.SendText(1, "hi", "", "")
+	if err != nil || id != 42 {
+		t.Fatalf("SendText = %d, %v; want 42", id, err)
+	}
+	if *calls != 2 {
+		t.Fatalf("calls = %d, want 2", *calls)
+	}
+}
+
+func TestTelegramFloodWaitAboveCapIsNotRetried(t *testing.T) {
+	tg, calls := newTestTelegram(t, func(n int, w http.ResponseWriter) {
+		reply(w, 429, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 600","parameters":{"retry_after":600}}`)
+	})
+
+	err := tg.EditText(1, 2, "hi", "", "")
+	var te *TelegramError
+	if !errors.As(err, &te) || !errors.Is(err, ErrTelegramFlood) || te.RetryAfter != 600 {
+		t.Fatalf("err = %v, want ErrTelegramFlood with RetryAfter 600", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("calls = %d, want 1 (600s is above telegramMaxFloodWait)", *calls)
+	}
+}
+
+func TestTelegramRetriesServerErrors(t *testing.T) {
+	tg, calls := newTestTelegram(t, func(n int, w http.ResponseWriter) {
+		reply(w, 502, `{"ok":false,"error_code":502,"description":"Bad Gateway"}`)
+	})
+
+	err := tg.DeleteMessage(1, 2)
+	if !errors.Is(err, ErrTelegramServer) {
+		t.Fatalf("err = %v, want ErrTelegramServer", err)
+	}
+	if *calls != telegramMaxAttempts {
+		t.Fatalf("calls = %d, want %d", *calls, telegramMaxAttempts)
+	}
+}
+
+func TestTelegramClientErrorsAreNotRetried(t *testing.T) {
+	cases := []struct {
+		code int
+		kind error
+	}{
+		{400, ErrTelegramBadRequest},
+		{401, ErrTelegramUnauthorized},
+		{403, ErrTelegramForbidden},
+		{404, ErrTelegramNotFound},
+	}
+	for _, c := range cases {
+		tg, calls := newTestTelegram(t, func(n int, w http.ResponseWriter) {
+			reply(w, c.code, `{"ok":false,"error_code":`+strconv.Itoa(c.code)+`,"description":"nope"}`)
+		})
+		err := tg.AnswerCallback("q")
+		if !errors.Is(err, c.kind) {
+			t.Errorf("code %d: err = %v, want %v", c.code, err, c.kind)
+		}
+		if *calls != 1 {
+			t.Errorf("code %d: calls = %d, want 1", c.code, *calls)
+		}
+	}
+}
+
+func TestTelegramNonJSONResponse(t *testing.T) {
+	tg, calls := newTestTelegram(t, func(n int, w http.ResponseWriter) {
+		w.WriteHeader(413)
+		io.WriteString(w, "<html>Request Entity Too Large</html>")
+	})
+	err := tg.SendChatAction(1, "typing", "")
+	var te *TelegramError
+	if !errors.As(err, &te) || !errors.Is(err, ErrTelegramBadRequest) || te.Code != 413 || !strings.Contains(te.Description, "invalid response") {
+		t.Fatalf("err = %v, want a bad request with code 413", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("calls = %d, want 1", *calls)
+	}
+
+	// Body 2xx yang bukan JSON dianggap gangguan server dan dicoba lagi
+	tg, calls = newTestTelegram(t, func(n int, w http.ResponseWriter) {
+		io.WriteString(w, "not json")
+	})
+	if err := tg.SendChatAction(1, "typing", ""); !errors.Is(err, ErrTelegramServer) {
+		t.Fatalf("err = %v, want ErrTelegramServer", err)
+	}
+	if *calls != telegramMaxAttempts {
+		t.Fatalf("calls = %d, want %d", *calls, telegramMaxAttempts)
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	tg := NewTelegramClient(testBotToken)
+	tg.BaseURL = srv.URL + "/bot" + testBotToken
+	err := tg.DeleteWebhook()
+	if !errors.Is(err, ErrTelegramNetwork) {
+		t.Fatalf("err = %v, want ErrTelegramNetwork", err)
+	}
+	if strings.Contains(err.Error(), "SECRET") {
+		t.Fatalf("error leaks the bot token: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Jenis error penyimpanan. Gunakan errors.Is(err, ErrTransient) dan seterusnya untuk
+// memutuskan apakah harus mencoba ulang, memberi tahu owner, atau melewati balasan.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrConflict  = errors.New("conflict")
+	ErrAuth      = errors.New("unauthorized")
+	ErrTransient = errors.New("transient failure")
+	// ErrInvalid menandakan konfigurasi/skema yang salah: tabel tidak ada, kolom salah, respons tidak bisa dibaca
+	ErrInvalid = errors.New("invalid request or response")
+)
+
+// Error membungkus kegagalan sebuah operasi database beserta jenisnya.
+type Error struct {
+	Op     string
+	Kind   error
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("database %s: %v", e.Op, e.Kind)
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func newError(op string, kind error, err error) error {
+	return &Error{Op: op, Kind: kind, Err: err}
+}
+
+// statusError mengklasifikasikan status HTTP dari PostgREST.
+func statusError(op string, status int, body []byte) error {
+	kind := ErrInvalid
+	switch {
+	case status == 401 || status == 403:
+		kind = ErrAuth
+	case status == 409:
+		kind = ErrConflict
+	case status == 408 || status == 429 || status >= 500:
+		kind = ErrTransient
+	}
+	return &Error{Op: op, Kind: kind, Status: status, Err: errors.New(string(body))}
+}
+
+// Retry menjalankan fn hingga attempts kali selama error-nya bersifat sementara,
+// dengan jeda yang menggandakan diri (200ms, 400ms, ...).
+func Retry(attempts int, fn func() error) error {
+	delay := 200 * time.Millisecond
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil || !errors.Is(err, ErrTransient) {
+			return err
+		}
+		if i < attempts-1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
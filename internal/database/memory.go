@@ -0,0 +1,375 @@
+package database
+
+import (
+	"sync"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore menyimpan semua data di memori proses. Cocok untuk test dan development;
+// semua data hilang saat proses berhenti.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	users     map[int64]models.User
+	messages  []memoryMessage
+	dialogs   map[int64]models.DialogState
+	usage     []models.UsageRecord
+	summaries map[[2]int64]models.ConversationSummary
+	pauses    map[[2]int64]models.ChatPause
+	documents []models.KnowledgeDocument
+	chunks    []models.KnowledgeChunk
+	lastDocID int64
+	offset    int64
+}
+
+type memoryMessage struct {
+	OwnerID      int64
+	CustomerID   int64
+	CustomerName string
+	Role         string
+	Content      string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[int64]models.User), dialogs: make(map[int64]models.DialogState), summaries: make(map[[2]int64]models.ConversationSummary), pauses: make(map[[2]int64]models.ChatPause)}
+}
+
+func (m *MemoryStore) GetUser(telegramID int64) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[telegramID]
+	if !ok {
+		return nil, newError("get user", ErrNotFound, nil)
+	}
+	return &user, nil
+}
+
+func (m *MemoryStore) GetUserByBusinessConnID(connID string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, user := range m.users {
+		if connID != "" && user.BusinessConnID == connID {
+			u := user
+			return &u, nil
+		}
+	}
+	return nil, newError("get user by business connection", ErrNotFound, nil)
+}
+
+func (m *MemoryStore) UpsertUser(user models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.users[user.TelegramID]; ok && user.CreatedAt == "" {
+		user.CreatedAt = existing.CreatedAt
+	}
+	if user.CreatedAt == "" {
+		user.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	m.users[user.TelegramID] = user
+	return nil
+}
+
+func (m *MemoryStore) UpdateKeyStatus(ownerID int64, encryptedKey, status, checkedAt string) (bool, error) {
+	return m.updateUserIfKey("update key status", ownerID, encryptedKey, func(user *models.User) {
+		user.KeyStatus, user.KeyCheckedAt = status, checkedAt
+	})
+}
+
+func (m *MemoryStore) ReplaceEncryptedKey(ownerID int64, oldKey, newKey string) (bool, error) {
+	return m.updateUserIfKey("replace encrypted key", ownerID, oldKey, func(user *models.User) {
+		user.EncryptedGroqKey = newKey
+	})
+}
+
+func (m *MemoryStore) updateUserIfKey(op string, ownerID int64, encryptedKey string, apply func(*models.User)) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[ownerID]
+	if !ok {
+		return false, newError(op, ErrNotFound, nil)
+	}
+	if user.EncryptedGroqKey != encryptedKey {
+		return false, nil
+	}
+	apply(&user)
+	m.users[ownerID] = user
+	return true, nil
+}
+
+func (m *MemoryStore) ListUsers() ([]models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var users []models.User
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (m *MemoryStore) SaveMessage(ownerID, customerID int64, customerName, role, content string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, memoryMessage{OwnerID: ownerID, CustomerID: customerID, CustomerName: customerName, Role: role, Content: content})
+	return nil
+}
+
+func (m *MemoryStore) GetChatHistory(ownerID, customerID int64, limit int) ([]models.ChatMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var history []models.ChatMessage
+	for i := len(m.messages) - 1; i >= 0 && len(history) < limit; i-- {
+		msg := m.messages[i]
+		if msg.OwnerID == ownerID && msg.CustomerID == customerID {
+			history = append(history, models.ChatMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	// Dikumpulkan dari yang terbaru, dibalik supaya urut kronologis
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+func (m *MemoryStore) CountMessages(ownerID, customerID int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	count := 0
+	for _, msg := range m.messages {
+		if msg.OwnerID == ownerID && msg.CustomerID == customerID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) GetMessages(ownerID, customerID int64, offset, limit int) ([]models.ChatMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var msgs []models.ChatMessage
+	seen := 0
+	for _, msg := range m.messages {
+		if msg.OwnerID != ownerID || msg.CustomerID != customerID {
+			continue
+		}
+		seen++
+		if seen > offset && len(msgs) < limit {
+			msgs = append(msgs, models.ChatMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	return msgs, nil
+}
+
+func (m *MemoryStore) GetBusinessCustomers(ownerID int64) ([]map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uniqueCustomers := make(map[int64]string)
+	for _, msg := range m.messages {
+		if msg.OwnerID == ownerID {
+			addCustomer(uniqueCustomers, msg.CustomerID, msg.CustomerName)
+		}
+	}
+	return customerList(uniqueCustomers), nil
+}
+
+func (m *MemoryStore) ClearHistoryPerUser(ownerID, customerID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.messages[:0]
+	for _, msg := range m.messages {
+		if msg.OwnerID != ownerID || msg.CustomerID != customerID {
+			kept = append(kept, msg)
+		}
+	}
+	m.messages = kept
+	delete(m.summaries, [2]int64{ownerID, customerID})
+	return nil
+}
+
+func (m *MemoryStore) GetSummary(ownerID, customerID int64) (*models.ConversationSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	summary, ok := m.summaries[[2]int64{ownerID, customerID}]
+	if !ok {
+		return nil, newError("get summary", ErrNotFound, nil)
+	}
+	return &summary, nil
+}
+
+func (m *MemoryStore) SaveSummary(summary models.ConversationSummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.summaries[[2]int64{summary.OwnerID, summary.CustomerID}] = summary
+	return nil
+}
+
+func (m *MemoryStore) GetChatPause(ownerID, customerID int64) (*models.ChatPause, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pause, ok := m.pauses[[2]int64{ownerID, customerID}]
+	if !ok {
+		return nil, newError("get chat pause", ErrNotFound, nil)
+	}
+	return &pause, nil
+}
+
+func (m *MemoryStore) SaveChatPause(pause models.ChatPause) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauses[[2]int64{pause.OwnerID, pause.CustomerID}] = pause
+	return nil
+}
+
+func (m *MemoryStore) DeleteChatPause(ownerID, customerID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pauses, [2]int64{ownerID, customerID})
+	return nil
+}
+
+func (m *MemoryStore) ListChatPauses(ownerID int64, now time.Time) ([]models.ChatPause, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var pauses []models.ChatPause
+	for _, pause := range m.pauses {
+		if pause.OwnerID == ownerID && pause.PausedUntil.After(now) {
+			pauses = append(pauses, pause)
+		}
+	}
+	return pauses, nil
+}
+
+func (m *MemoryStore) SaveKnowledgeDocument(doc models.KnowledgeDocument, chunks []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastDocID++
+	doc.ID = m.lastDocID
+	doc.ChunkCount = len(chunks)
+	m.documents = append(m.documents, doc)
+	for i, content := range chunks {
+		m.chunks = append(m.chunks, models.KnowledgeChunk{DocumentID: doc.ID, OwnerID: doc.OwnerID, Position: i, Content: content})
+	}
+	return doc.ID, nil
+}
+
+func (m *MemoryStore) ListKnowledgeDocuments(ownerID int64) ([]models.KnowledgeDocument, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var docs []models.KnowledgeDocument
+	for _, doc := range m.documents {
+		if doc.OwnerID == ownerID {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func (m *MemoryStore) DeleteKnowledgeDocument(ownerID, documentID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs := m.documents[:0]
+	for _, doc := range m.documents {
+		if doc.OwnerID != ownerID || doc.ID != documentID {
+			docs = append(docs, doc)
+		}
+	}
+	m.documents = docs
+	chunks := m.chunks[:0]
+	for _, chunk := range m.chunks {
+		if chunk.OwnerID != ownerID || chunk.DocumentID != documentID {
+			chunks = append(chunks, chunk)
+		}
+	}
+	m.chunks = chunks
+	return nil
+}
+
+func (m *MemoryStore) ListKnowledgeChunks(ownerID int64) ([]models.KnowledgeChunk, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var chunks []models.KnowledgeChunk
+	for _, chunk := range m.chunks {
+		if chunk.OwnerID == ownerID {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
+
+func (m *MemoryStore) GetDialogState(telegramID int64) (*models.DialogState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.dialogs[telegramID]
+	if !ok {
+		return nil, newError("get dialog state", ErrNotFound, nil)
+	}
+	return &state, nil
+}
+
+func (m *MemoryStore) SaveDialogState(state models.DialogState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dialogs[state.TelegramID] = state
+	return nil
+}
+
+func (m *MemoryStore) ClearDialogState(telegramID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.dialogs, telegramID)
+	return nil
+}
+
+func (m *MemoryStore) ListExpiredDialogStates(now time.Time) ([]models.DialogState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var expired []models.DialogState
+	for _, state := range m.dialogs {
+		if state.Expired(now) {
+			expired = append(expired, state)
+		}
+	}
+	return expired, nil
+}
+
+func (m *MemoryStore) RecordUsage(rec models.UsageRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = append(m.usage, rec)
+	return nil
+}
+
+func (m *MemoryStore) ListUsage(ownerID int64, since time.Time) ([]models.UsageRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var records []models.UsageRecord
+	for _, rec := range m.usage {
+		if rec.OwnerID == ownerID && !rec.CreatedAt.Before(since) {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+func (m *MemoryStore) SumUsage(ownerID int64, since time.Time) (models.UsageTotals, error) {
+	records, _ := m.ListUsage(ownerID, since)
+	var totals models.UsageTotals
+	for _, rec := range records {
+		totals.Add(rec)
+	}
+	return totals, nil
+}
+
+func (m *MemoryStore) GetUpdateOffset() (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.offset, nil
+}
+
+func (m *MemoryStore) SaveUpdateOffset(offset int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offset = offset
+	return nil
+}
@@ -0,0 +1,508 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+var _ Store = (*SQLStore)(nil)
+
+// SQLStore adalah backend database/sql yang dipakai bersama oleh Postgres dan SQLite.
+// Data user disimpan sebagai JSON supaya field baru di models.User tidak butuh migrasi kolom.
+type SQLStore struct {
+	db *sql.DB
+	// numbered bernilai true untuk placeholder $1, $2 (Postgres), false untuk ? (SQLite)
+	numbered bool
+	// classify memetakan error driver ke ErrConflict, ErrAuth, ErrTransient atau ErrInvalid
+	classify func(error) error
+}
+
+func newSQLStore(db *sql.DB, numbered bool, classify func(error) error, schema []string) (*SQLStore, error) {
+	s := &SQLStore{db: db, numbered: numbered, classify: classify}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, s.wrap("connect", err)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, s.wrap("migrate schema", err)
+		}
+	}
+	return s, nil
+}
+
+func (s *SQLStore) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == sql.ErrNoRows {
+		return newError(op, ErrNotFound, nil)
+	}
+	return newError(op, s.classify(err), err)
+}
+
+// rebind mengubah placeholder ? menjadi $n untuk driver Postgres.
+func (s *SQLStore) rebind(query string) string {
+	if !s.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *SQLStore) queryUser(op, query string, arg interface{}) (*models.User, error) {
+	var data string
+	if err := s.db.QueryRow(s.rebind(query), arg).Scan(&data); err != nil {
+		return nil, s.wrap(op, err)
+	}
+	var user models.User
+	if err := json.Unmarshal([]byte(data), &user); err != nil {
+		return nil, newError(op, ErrInvalid, err)
+	}
+	return &user, nil
+}
+
+func (s *SQLStore) GetUser(telegramID int64) (*models.User, error) {
+	return s.queryUser("get user", "SELECT data FROM users WHERE telegram_id = ?", telegramID)
+}
+
+func (s *SQLStore) GetUserByBusinessConnID(connID string) (*models.User, error) {
+	return s.queryUser("get user by business connection", "SELECT data FROM users WHERE business_connection_id = ? LIMIT 1", connID)
+}
+
+func (s *SQLStore) UpsertUser(user models.User) error {
+	if user.CreatedAt == "" {
+		existing, err := s.GetUser(user.TelegramID)
+		switch {
+		case err == nil:
+			user.CreatedAt = existing.CreatedAt
+		case errors.Is(err, ErrNotFound):
+			user.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		default:
+			return err
+		}
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return newError("upsert user", ErrInvalid, err)
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO users (telegram_id, business_connection_id, data) VALUES (?, ?, ?)
+		ON CONFLICT (telegram_id) DO UPDATE SET business_connection_id = excluded.business_connection_id, data = excluded.data`),
+		user.TelegramID, user.BusinessConnID, string(data))
+	return s.wrap("upsert user", err)
+}
+
+// casAttempts membatasi percobaan ulang compare-and-swap jika baris berubah bersamaan.
+const casAttempts = 5
+
+// UpdateKeyStatus mengubah status key di data JSON user jika key yang tersimpan masih encryptedKey.
+func (s *SQLStore) UpdateKeyStatus(ownerID int64, encryptedKey, status, checkedAt string) (bool, error) {
+	return s.updateUserIfKey("update key status", ownerID, encryptedKey, func(user *models.User) {
+		user.KeyStatus, user.KeyCheckedAt = status, checkedAt
+	})
+}
+
+// ReplaceEncryptedKey mengganti ciphertext API key jika yang tersimpan masih oldKey.
+func (s *SQLStore) ReplaceEncryptedKey(ownerID int64, oldKey, newKey string) (bool, error) {
+	return s.updateUserIfKey("replace encrypted key", ownerID, oldKey, func(user *models.User) {
+		user.EncryptedGroqKey = newKey
+	})
+}
+
+// updateUserIfKey menerapkan apply pada data JSON user selama key yang tersimpan masih
+// encryptedKey. Baris ditulis dengan compare-and-swap atas data yang dibaca, jadi perubahan
+// owner yang masuk bersamaan tidak tertimpa.
+func (s *SQLStore) updateUserIfKey(op string, ownerID int64, encryptedKey string, apply func(*models.User)) (bool, error) {
+	for attempt := 0; attempt < casAttempts; attempt++ {
+		var data string
+		if err := s.db.QueryRow(s.rebind("SELECT data FROM users WHERE telegram_id = ?"), ownerID).Scan(&data); err != nil {
+			return false, s.wrap(op, err)
+		}
+		var user models.User
+		if err := json.Unmarshal([]byte(data), &user); err != nil {
+			return false, newError(op, ErrInvalid, err)
+		}
+		if user.EncryptedGroqKey != encryptedKey {
+			return false, nil
+		}
+		apply(&user)
+		updated, err := json.Marshal(user)
+		if err != nil {
+			return false, newError(op, ErrInvalid, err)
+		}
+		res, err := s.db.Exec(s.rebind("UPDATE users SET data = ? WHERE telegram_id = ? AND data = ?"), string(updated), ownerID, data)
+		if err != nil {
+			return false, s.wrap(op, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return false, s.wrap(op, err)
+		}
+		if n == 1 {
+			return true, nil
+		}
+	}
+	return false, newError(op, ErrConflict, nil)
+}
+
+func (s *SQLStore) ListUsers() ([]models.User, error) {
+	rows, err := s.db.Query("SELECT data FROM users ORDER BY telegram_id")
+	if err != nil {
+		return nil, s.wrap("list users", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, s.wrap("list users", err)
+		}
+		var user models.User
+		if err := json.Unmarshal([]byte(data), &user); err != nil {
+			return nil, newError("list users", ErrInvalid, err)
+		}
+		users = append(users, user)
+	}
+	return users, s.wrap("list users", rows.Err())
+}
+
+func (s *SQLStore) SaveMessage(ownerID, customerID int64, customerName, role, content string) error {
+	_, err := s.db.Exec(s.rebind("INSERT INTO messages (owner_id, customer_id, customer_name, role, content, created_at) VALUES (?, ?, ?, ?, ?, ?)"),
+		ownerID, customerID, customerName, role, content, time.Now().UTC())
+	return s.wrap("save message", err)
+}
+
+func (s *SQLStore) GetChatHistory(ownerID, customerID int64, limit int) ([]models.ChatMessage, error) {
+	rows, err := s.db.Query(s.rebind("SELECT role, content FROM messages WHERE owner_id = ? AND customer_id = ? ORDER BY id DESC LIMIT ?"),
+		ownerID, customerID, limit)
+	if err != nil {
+		return nil, s.wrap("get chat history", err)
+	}
+	defer rows.Close()
+
+	var rawMsgs []models.ChatMessage
+	for rows.Next() {
+		var m models.ChatMessage
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, s.wrap("get chat history", err)
+		}
+		rawMsgs = append(rawMsgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.wrap("get chat history", err)
+	}
+	var history []models.ChatMessage
+	for i := len(rawMsgs) - 1; i >= 0; i-- {
+		history = append(history, rawMsgs[i])
+	}
+	return history, nil
+}
+
+func (s *SQLStore) CountMessages(ownerID, customerID int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(s.rebind("SELECT COUNT(*) FROM messages WHERE owner_id = ? AND customer_id = ?"), ownerID, customerID).Scan(&count)
+	return count, s.wrap("count messages", err)
+}
+
+func (s *SQLStore) GetMessages(ownerID, customerID int64, offset, limit int) ([]models.ChatMessage, error) {
+	rows, err := s.db.Query(s.rebind("SELECT role, content FROM messages WHERE owner_id = ? AND customer_id = ? ORDER BY id LIMIT ? OFFSET ?"),
+		ownerID, customerID, limit, offset)
+	if err != nil {
+		return nil, s.wrap("get messages", err)
+	}
+	defer rows.Close()
+
+	var msgs []models.ChatMessage
+	for rows.Next() {
+		var m models.ChatMessage
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, s.wrap("get messages", err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, s.wrap("get messages", rows.Err())
+}
+
+func (s *SQLStore) GetBusinessCustomers(ownerID int64) ([]map[string]interface{}, error) {
+	rows, err := s.db.Query(s.rebind("SELECT customer_id, customer_name FROM messages WHERE owner_id = ? ORDER BY id"), ownerID)
+	if err != nil {
+		return nil, s.wrap("get business customers", err)
+	}
+	defer rows.Close()
+
+	uniqueCustomers := make(map[int64]string)
+	for rows.Next() {
+		var cid int64
+		var name string
+		if err := rows.Scan(&cid, &name); err != nil {
+			return nil, s.wrap("get business customers", err)
+		}
+		addCustomer(uniqueCustomers, cid, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.wrap("get business customers", err)
+	}
+	return customerList(uniqueCustomers), nil
+}
+
+func (s *SQLStore) ClearHistoryPerUser(ownerID, customerID int64) error {
+	_, err := s.db.Exec(s.rebind("DELETE FROM messages WHERE owner_id = ? AND customer_id = ?"), ownerID, customerID)
+	if err != nil {
+		return s.wrap("clear history", err)
+	}
+	_, err = s.db.Exec(s.rebind("DELETE FROM conversation_summaries WHERE owner_id = ? AND customer_id = ?"), ownerID, customerID)
+	return s.wrap("clear history", err)
+}
+
+func (s *SQLStore) GetSummary(ownerID, customerID int64) (*models.ConversationSummary, error) {
+	summary := models.ConversationSummary{OwnerID: ownerID, CustomerID: customerID}
+	err := s.db.QueryRow(s.rebind("SELECT summary, covered_count, updated_at FROM conversation_summaries WHERE owner_id = ? AND customer_id = ?"),
+		ownerID, customerID).Scan(&summary.Summary, &summary.CoveredCount, &summary.UpdatedAt)
+	if err != nil {
+		return nil, s.wrap("get summary", err)
+	}
+	return &summary, nil
+}
+
+func (s *SQLStore) SaveSummary(summary models.ConversationSummary) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO conversation_summaries (owner_id, customer_id, summary, covered_count, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (owner_id, customer_id) DO UPDATE SET summary = excluded.summary, covered_count = excluded.covered_count, updated_at = excluded.updated_at`),
+		summary.OwnerID, summary.CustomerID, summary.Summary, summary.CoveredCount, summary.UpdatedAt.UTC())
+	return s.wrap("save summary", err)
+}
+
+func (s *SQLStore) GetChatPause(ownerID, customerID int64) (*models.ChatPause, error) {
+	pause := models.ChatPause{OwnerID: ownerID, CustomerID: customerID}
+	err := s.db.QueryRow(s.rebind("SELECT paused_until FROM chat_pauses WHERE owner_id = ? AND customer_id = ?"),
+		ownerID, customerID).Scan(&pause.PausedUntil)
+	if err != nil {
+		return nil, s.wrap("get chat pause", err)
+	}
+	return &pause, nil
+}
+
+func (s *SQLStore) SaveChatPause(pause models.ChatPause) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO chat_pauses (owner_id, customer_id, paused_until) VALUES (?, ?, ?)
+		ON CONFLICT (owner_id, customer_id) DO UPDATE SET paused_until = excluded.paused_until`),
+		pause.OwnerID, pause.CustomerID, pause.PausedUntil.UTC())
+	return s.wrap("save chat pause", err)
+}
+
+func (s *SQLStore) DeleteChatPause(ownerID, customerID int64) error {
+	_, err := s.db.Exec(s.rebind("DELETE FROM chat_pauses WHERE owner_id = ? AND customer_id = ?"), ownerID, customerID)
+	return s.wrap("delete chat pause", err)
+}
+
+func (s *SQLStore) ListChatPauses(ownerID int64, now time.Time) ([]models.ChatPause, error) {
+	rows, err := s.db.Query(s.rebind("SELECT customer_id, paused_until FROM chat_pauses WHERE owner_id = ? AND paused_until > ? ORDER BY paused_until"),
+		ownerID, now.UTC())
+	if err != nil {
+		return nil, s.wrap("list chat pauses", err)
+	}
+	defer rows.Close()
+
+	var pauses []models.ChatPause
+	for rows.Next() {
+		pause := models.ChatPause{OwnerID: ownerID}
+		if err := rows.Scan(&pause.CustomerID, &pause.PausedUntil); err != nil {
+			return nil, s.wrap("list chat pauses", err)
+		}
+		pauses = append(pauses, pause)
+	}
+	return pauses, s.wrap("list chat pauses", rows.Err())
+}
+
+// SaveKnowledgeDocument menyimpan dokumen dan potongannya dalam satu transaksi supaya tidak
+// ada dokumen setengah jadi jika salah satu insert gagal.
+func (s *SQLStore) SaveKnowledgeDocument(doc models.KnowledgeDocument, chunks []string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, s.wrap("save knowledge document", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(s.rebind("INSERT INTO knowledge_documents (owner_id, name, chunk_count, created_at) VALUES (?, ?, ?, ?) RETURNING id"),
+		doc.OwnerID, doc.Name, len(chunks), doc.CreatedAt.UTC()).Scan(&id)
+	if err != nil {
+		return 0, s.wrap("save knowledge document", err)
+	}
+	stmt, err := tx.Prepare(s.rebind("INSERT INTO knowledge_chunks (document_id, owner_id, position, content) VALUES (?, ?, ?, ?)"))
+	if err != nil {
+		return 0, s.wrap("save knowledge document", err)
+	}
+	defer stmt.Close()
+	for i, content := range chunks {
+		if _, err := stmt.Exec(id, doc.OwnerID, i, content); err != nil {
+			return 0, s.wrap("save knowledge document", err)
+		}
+	}
+	return id, s.wrap("save knowledge document", tx.Commit())
+}
+
+func (s *SQLStore) ListKnowledgeDocuments(ownerID int64) ([]models.KnowledgeDocument, error) {
+	rows, err := s.db.Query(s.rebind("SELECT id, name, chunk_count, created_at FROM knowledge_documents WHERE owner_id = ? ORDER BY id"), ownerID)
+	if err != nil {
+		return nil, s.wrap("list knowledge documents", err)
+	}
+	defer rows.Close()
+
+	var docs []models.KnowledgeDocument
+	for rows.Next() {
+		doc := models.KnowledgeDocument{OwnerID: ownerID}
+		if err := rows.Scan(&doc.ID, &doc.Name, &doc.ChunkCount, &doc.CreatedAt); err != nil {
+			return nil, s.wrap("list knowledge documents", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, s.wrap("list knowledge documents", rows.Err())
+}
+
+func (s *SQLStore) DeleteKnowledgeDocument(ownerID, documentID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return s.wrap("delete knowledge document", err)
+	}
+	defer tx.Rollback()
+	// owner_id ikut diperiksa supaya owner tidak bisa menghapus dokumen milik owner lain
+	if _, err := tx.Exec(s.rebind("DELETE FROM knowledge_chunks WHERE owner_id = ? AND document_id = ?"), ownerID, documentID); err != nil {
+		return s.wrap("delete knowledge document", err)
+	}
+	if _, err := tx.Exec(s.rebind("DELETE FROM knowledge_documents WHERE owner_id = ? AND id = ?"), ownerID, documentID); err != nil {
+		return s.wrap("delete knowledge document", err)
+	}
+	return s.wrap("delete knowledge document", tx.Commit())
+}
+
+func (s *SQLStore) ListKnowledgeChunks(ownerID int64) ([]models.KnowledgeChunk, error) {
+	rows, err := s.db.Query(s.rebind("SELECT document_id, position, content FROM knowledge_chunks WHERE owner_id = ? ORDER BY document_id, position"), ownerID)
+	if err != nil {
+		return nil, s.wrap("list knowledge chunks", err)
+	}
+	defer rows.Close()
+
+	var chunks []models.KnowledgeChunk
+	for rows.Next() {
+		chunk := models.KnowledgeChunk{OwnerID: ownerID}
+		if err := rows.Scan(&chunk.DocumentID, &chunk.Position, &chunk.Content); err != nil {
+			return nil, s.wrap("list knowledge chunks", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, s.wrap("list knowledge chunks", rows.Err())
+}
+
+func (s *SQLStore) GetDialogState(telegramID int64) (*models.DialogState, error) {
+	var data string
+	if err := s.db.QueryRow(s.rebind("SELECT data FROM dialog_states WHERE telegram_id = ?"), telegramID).Scan(&data); err != nil {
+		return nil, s.wrap("get dialog state", err)
+	}
+	var state models.DialogState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, newError("get dialog state", ErrInvalid, err)
+	}
+	return &state, nil
+}
+
+func (s *SQLStore) SaveDialogState(state models.DialogState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return newError("save dialog state", ErrInvalid, err)
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO dialog_states (telegram_id, expires_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (telegram_id) DO UPDATE SET expires_at = excluded.expires_at, data = excluded.data`),
+		state.TelegramID, state.ExpiresAt.UTC(), string(data))
+	return s.wrap("save dialog state", err)
+}
+
+func (s *SQLStore) ClearDialogState(telegramID int64) error {
+	_, err := s.db.Exec(s.rebind("DELETE FROM dialog_states WHERE telegram_id = ?"), telegramID)
+	return s.wrap("clear dialog state", err)
+}
+
+func (s *SQLStore) ListExpiredDialogStates(now time.Time) ([]models.DialogState, error) {
+	rows, err := s.db.Query(s.rebind("SELECT data FROM dialog_states WHERE expires_at < ?"), now.UTC())
+	if err != nil {
+		return nil, s.wrap("list expired dialog states", err)
+	}
+	defer rows.Close()
+
+	var states []models.DialogState
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, s.wrap("list expired dialog states", err)
+		}
+		var state models.DialogState
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return nil, newError("list expired dialog states", ErrInvalid, err)
+		}
+		states = append(states, state)
+	}
+	return states, s.wrap("list expired dialog states", rows.Err())
+}
+
+func (s *SQLStore) RecordUsage(rec models.UsageRecord) error {
+	_, err := s.db.Exec(s.rebind("INSERT INTO ai_usage (owner_id, customer_id, model, shared_key, prompt_tokens, completion_tokens, cost, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		rec.OwnerID, rec.CustomerID, rec.Model, rec.SharedKey, rec.PromptTokens, rec.CompletionTokens, rec.Cost, rec.CreatedAt.UTC())
+	return s.wrap("record usage", err)
+}
+
+func (s *SQLStore) SumUsage(ownerID int64, since time.Time) (models.UsageTotals, error) {
+	var totals models.UsageTotals
+	err := s.db.QueryRow(s.rebind(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN shared_key THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost), 0)
+		FROM ai_usage WHERE owner_id = ? AND created_at >= ?`), ownerID, since.UTC()).
+		Scan(&totals.Replies, &totals.SharedReplies, &totals.PromptTokens, &totals.CompletionTokens, &totals.Cost)
+	return totals, s.wrap("sum usage", err)
+}
+
+func (s *SQLStore) ListUsage(ownerID int64, since time.Time) ([]models.UsageRecord, error) {
+	rows, err := s.db.Query(s.rebind("SELECT owner_id, customer_id, model, shared_key, prompt_tokens, completion_tokens, cost, created_at FROM ai_usage WHERE owner_id = ? AND created_at >= ? ORDER BY id"),
+		ownerID, since.UTC())
+	if err != nil {
+		return nil, s.wrap("list usage", err)
+	}
+	defer rows.Close()
+
+	var records []models.UsageRecord
+	for rows.Next() {
+		var rec models.UsageRecord
+		if err := rows.Scan(&rec.OwnerID, &rec.CustomerID, &rec.Model, &rec.SharedKey, &rec.PromptTokens, &rec.CompletionTokens, &rec.Cost, &rec.CreatedAt); err != nil {
+			return nil, s.wrap("list usage", err)
+		}
+		records = append(records, rec)
+	}
+	return records, s.wrap("list usage", rows.Err())
+}
+
+func (s *SQLStore) GetUpdateOffset() (int64, error) {
+	var offset int64
+	err := s.db.QueryRow(s.rebind("SELECT value FROM bot_state WHERE key = ?"), "update_offset").Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return offset, s.wrap("get update offset", err)
+}
+
+func (s *SQLStore) SaveUpdateOffset(offset int64) error {
+	_, err := s.db.Exec(s.rebind(`INSERT INTO bot_state (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`), "update_offset", offset)
+	return s.wrap("save update offset", err)
+}
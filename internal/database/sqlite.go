@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		telegram_id INTEGER PRIMARY KEY,
+		business_connection_id TEXT NOT NULL DEFAULT '',
+		data TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS users_business_connection_id_idx ON users (business_connection_id)`,
+	`CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL,
+		customer_id INTEGER NOT NULL,
+		customer_name TEXT NOT NULL,
+		role TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS messages_owner_customer_idx ON messages (owner_id, customer_id)`,
+	`CREATE TABLE IF NOT EXISTS conversation_summaries (
+		owner_id INTEGER NOT NULL,
+		customer_id INTEGER NOT NULL,
+		summary TEXT NOT NULL,
+		covered_count INTEGER NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (owner_id, customer_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS chat_pauses (
+		owner_id INTEGER NOT NULL,
+		customer_id INTEGER NOT NULL,
+		paused_until TIMESTAMP NOT NULL,
+		PRIMARY KEY (owner_id, customer_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS knowledge_documents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		chunk_count INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS knowledge_documents_owner_idx ON knowledge_documents (owner_id)`,
+	`CREATE TABLE IF NOT EXISTS knowledge_chunks (
+		document_id INTEGER NOT NULL,
+		owner_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		PRIMARY KEY (document_id, position)
+	)`,
+	`CREATE INDEX IF NOT EXISTS knowledge_chunks_owner_idx ON knowledge_chunks (owner_id)`,
+	`CREATE TABLE IF NOT EXISTS dialog_states (
+		telegram_id INTEGER PRIMARY KEY,
+		expires_at TIMESTAMP NOT NULL,
+		data TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS dialog_states_expires_at_idx ON dialog_states (expires_at)`,
+	`CREATE TABLE IF NOT EXISTS ai_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL,
+		customer_id INTEGER NOT NULL,
+		model TEXT NOT NULL,
+		shared_key BOOLEAN NOT NULL DEFAULT FALSE,
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		cost DOUBLE PRECISION NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS ai_usage_owner_created_idx ON ai_usage (owner_id, created_at)`,
+	`CREATE TABLE IF NOT EXISTS bot_state (
+		key TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	)`,
+}
+
+// NewSQLiteStore membuka (atau membuat) file database SQLite lokal, misalnya "data/bot.db".
+func NewSQLiteStore(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite hanya mengizinkan satu penulis; worker pool dibatasi ke satu koneksi tulis
+	db.SetMaxOpenConns(1)
+	return newSQLStore(db, false, classifySQLite, sqliteSchema)
+}
+
+// classifySQLite memetakan kode error SQLite ke jenis error penyimpanan.
+func classifySQLite(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrConstraint:
+			return ErrConflict
+		case sqlite3.ErrAuth, sqlite3.ErrPerm, sqlite3.ErrReadonly:
+			return ErrAuth
+		case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrIoErr:
+			return ErrTransient
+		}
+	}
+	return ErrInvalid
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// Store adalah kontrak penyimpanan yang dipakai handler. Setiap backend (Supabase, Postgres,
+// SQLite, in-memory) mengimplementasikan interface ini sehingga bisa dipilih lewat config.
+// Semua error dikembalikan sebagai *Error; GetUser dan GetUserByBusinessConnID mengembalikan
+// ErrNotFound jika user tidak ada.
+type Store interface {
+	GetUser(telegramID int64) (*models.User, error)
+	GetUserByBusinessConnID(connID string) (*models.User, error)
+	UpsertUser(user models.User) error
+	// ListUsers mengembalikan semua user, dipakai oleh job latar belakang (cek key, rotasi).
+	ListUsers() ([]models.User, error)
+	// UpdateKeyStatus menyimpan hasil cek API key tanpa menyentuh kolom lain, dan hanya jika key
+	// yang tersimpan masih encryptedKey. updated bernilai false jika key sudah diganti owner.
+	UpdateKeyStatus(ownerID int64, encryptedKey, status, checkedAt string) (updated bool, err error)
+	// ReplaceEncryptedKey menyimpan ciphertext baru hasil rotasi, juga hanya jika yang tersimpan
+	// masih oldKey, sehingga key yang diganti owner selama rotasi tidak tertimpa.
+	ReplaceEncryptedKey(ownerID int64, oldKey, newKey string) (updated bool, err error)
+
+	SaveMessage(ownerID, customerID int64, customerName, role, content string) error
+	// GetChatHistory mengembalikan paling banyak limit pesan terbaru, urut kronologis.
+	GetChatHistory(ownerID, customerID int64, limit int) ([]models.ChatMessage, error)
+	// CountMessages dan GetMessages (offset dari pesan terlama) dipakai untuk ringkasan percakapan.
+	CountMessages(ownerID, customerID int64) (int, error)
+	GetMessages(ownerID, customerID int64, offset, limit int) ([]models.ChatMessage, error)
+	GetBusinessCustomers(ownerID int64) ([]map[string]interface{}, error)
+	// ClearHistoryPerUser juga menghapus ringkasan percakapan pelanggan tersebut.
+	ClearHistoryPerUser(ownerID, customerID int64) error
+
+	// GetSummary mengembalikan ErrNotFound jika percakapan belum punya ringkasan.
+	GetSummary(ownerID, customerID int64) (*models.ConversationSummary, error)
+	SaveSummary(summary models.ConversationSummary) error
+
+	// Jeda AI per pelanggan saat owner membalas sendiri. GetChatPause mengembalikan ErrNotFound
+	// jika percakapan tidak dijeda; ListChatPauses hanya mengembalikan jeda yang masih berlaku.
+	GetChatPause(ownerID, customerID int64) (*models.ChatPause, error)
+	SaveChatPause(pause models.ChatPause) error
+	DeleteChatPause(ownerID, customerID int64) error
+	ListChatPauses(ownerID int64, now time.Time) ([]models.ChatPause, error)
+
+	// Knowledge base owner. SaveKnowledgeDocument menyimpan dokumen beserta potongannya dan
+	// mengembalikan ID dokumen baru; DeleteKnowledgeDocument ikut menghapus potongannya.
+	// ListKnowledgeChunks mengembalikan semua potongan owner, urut per dokumen dan posisi.
+	SaveKnowledgeDocument(doc models.KnowledgeDocument, chunks []string) (int64, error)
+	ListKnowledgeDocuments(ownerID int64) ([]models.KnowledgeDocument, error)
+	DeleteKnowledgeDocument(ownerID, documentID int64) error
+	ListKnowledgeChunks(ownerID int64) ([]models.KnowledgeChunk, error)
+
+	// Dialog state: input yang sedang ditunggu dari owner. GetDialogState mengembalikan
+	// ErrNotFound jika tidak ada dialog aktif.
+	GetDialogState(telegramID int64) (*models.DialogState, error)
+	SaveDialogState(state models.DialogState) error
+	ClearDialogState(telegramID int64) error
+	ListExpiredDialogStates(now time.Time) ([]models.DialogState, error)
+
+	// Pemakaian AI dicatat per balasan (insert-only) supaya penulis paralel tidak saling timpa.
+	RecordUsage(rec models.UsageRecord) error
+	// ListUsage memuat catatan satu per satu untuk laporan rinci (per model dan per pelanggan).
+	ListUsage(ownerID int64, since time.Time) ([]models.UsageRecord, error)
+	// SumUsage menjumlahkan pemakaian owner sejak since tanpa memuat baris satu per satu.
+	SumUsage(ownerID int64, since time.Time) (models.UsageTotals, error)
+
+	GetUpdateOffset() (int64, error)
+	SaveUpdateOffset(offset int64) error
+}
+
+// NewStore membuat backend penyimpanan sesuai driver: "supabase", "postgres", "sqlite" atau "memory".
+func NewStore(driver, supabaseURL, supabaseKey, databaseURL string) (Store, error) {
+	switch driver {
+	case "supabase":
+		return NewSupabaseClient(supabaseURL, supabaseKey), nil
+	case "postgres":
+		return NewPostgresStore(databaseURL)
+	case "sqlite":
+		return NewSQLiteStore(databaseURL)
+	case "memory":
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown storage driver: %s", driver)
+}
+
+// addCustomer mengumpulkan pelanggan unik dan memprioritaskan nama yang bukan "Unknown" atau kosong.
+func addCustomer(unique map[int64]string, cid int64, name string) {
+	if cid == 0 {
+		return
+	}
+	if name != "" && name != "Unknown" {
+		unique[cid] = name
+	} else if _, exists := unique[cid]; !exists {
+		unique[cid] = fmt.Sprintf("User %d", cid)
+	}
+}
+
+func customerList(unique map[int64]string) []map[string]interface{} {
+	var list []map[string]interface{}
+	for id, name := range unique {
+		list = append(list, map[string]interface{}{"customer_id": id, "customer_name": name})
+	}
+	return list
+}
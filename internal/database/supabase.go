@@ -6,109 +6,211 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"tg-business-bot/internal/models"
+	"time"
 )
 
+var _ Store = (*SupabaseClient)(nil)
+
+// SupabaseClient menyimpan data lewat REST API PostgREST milik Supabase.
 type SupabaseClient struct {
-	URL string
-	Key string
+	URL    string
+	Key    string
+	client *http.Client
 }
 
 func NewSupabaseClient(url, key string) *SupabaseClient {
-	return &SupabaseClient{URL: url, Key: key}
+	return &SupabaseClient{URL: url, Key: key, client: &http.Client{Timeout: 15 * time.Second}}
 }
 
-func (s *SupabaseClient) GetUser(telegramID int64) (*models.User, error) {
-	url := fmt.Sprintf("%s/rest/v1/users?telegram_id=eq.%d&select=*", s.URL, telegramID)
-	req, _ := http.NewRequest("GET", url, nil)
+// do mengirim request ke PostgREST dan mengubah setiap kegagalan (jaringan, status non-2xx,
+// JSON yang tidak bisa dibaca) menjadi *Error yang jenisnya bisa diperiksa pemanggil.
+func (s *SupabaseClient) do(op, method, url string, payload interface{}, prefer string, out interface{}) error {
+	_, respBody, err := s.send(op, method, url, payload, prefer)
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return newError(op, ErrInvalid, err)
+		}
+	}
+	return nil
+}
+
+// send menjalankan request dan mengembalikan header serta body respons 2xx.
+func (s *SupabaseClient) send(op, method, url string, payload interface{}, prefer string) (http.Header, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, nil, newError(op, ErrInvalid, err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, nil, newError(op, ErrInvalid, err)
+	}
 	req.Header.Set("apikey", s.Key)
 	req.Header.Set("Authorization", "Bearer "+s.Key)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil { return nil, err }
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, newError(op, ErrTransient, err)
+	}
 	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, newError(op, ErrTransient, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, statusError(op, resp.StatusCode, respBody)
+	}
+	return resp.Header, respBody, nil
+}
+
+// count menghitung baris yang cocok dengan url di Postgres: HEAD dengan "Prefer: count=exact"
+// tidak mengirim baris apa pun, jumlahnya dibaca dari Content-Range ("0-24/25" atau "*/0").
+func (s *SupabaseClient) count(op, url string) (int, error) {
+	header, _, err := s.send(op, "HEAD", url, nil, "count=exact")
+	if err != nil {
+		return 0, err
+	}
+	contentRange := header.Get("Content-Range")
+	slash := strings.LastIndex(contentRange, "/")
+	if slash < 0 {
+		return 0, newError(op, ErrInvalid, fmt.Errorf("missing count in Content-Range %q", contentRange))
+	}
+	n, err := strconv.Atoi(contentRange[slash+1:])
+	if err != nil {
+		return 0, newError(op, ErrInvalid, fmt.Errorf("invalid Content-Range %q", contentRange))
+	}
+	return n, nil
+}
+
+func (s *SupabaseClient) GetUser(telegramID int64) (*models.User, error) {
+	url := fmt.Sprintf("%s/rest/v1/users?telegram_id=eq.%d&select=*", s.URL, telegramID)
 	var users []models.User
-	body, _ := io.ReadAll(resp.Body)
-	json.Unmarshal(body, &users)
-	if len(users) == 0 { return nil, nil }
+	if err := s.do("get user", "GET", url, nil, "", &users); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, newError("get user", ErrNotFound, nil)
+	}
 	return &users[0], nil
 }
 
 func (s *SupabaseClient) GetUserByBusinessConnID(connID string) (*models.User, error) {
 	url := fmt.Sprintf("%s/rest/v1/users?business_connection_id=eq.%s&select=*", s.URL, connID)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("apikey", s.Key)
-	req.Header.Set("Authorization", "Bearer "+s.Key)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil { return nil, err }
-	defer resp.Body.Close()
 	var users []models.User
-	body, _ := io.ReadAll(resp.Body)
-	json.Unmarshal(body, &users)
-	if len(users) == 0 { return nil, nil }
+	if err := s.do("get user by business connection", "GET", url, nil, "", &users); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, newError("get user by business connection", ErrNotFound, nil)
+	}
 	return &users[0], nil
 }
 
 func (s *SupabaseClient) UpsertUser(user models.User) error {
 	url := fmt.Sprintf("%s/rest/v1/users", s.URL)
-	jsonData, _ := json.Marshal(user)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("apikey", s.Key)
-	req.Header.Set("Authorization", "Bearer "+s.Key)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "resolution=merge-duplicates,return=minimal")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil { return err }
-	defer resp.Body.Close()
-	return nil
+	return s.do("upsert user", "POST", url, user, "resolution=merge-duplicates,return=minimal", nil)
+}
+
+// UpdateKeyStatus hanya mengubah kolom status key, dengan syarat encrypted_groq_key masih sama.
+func (s *SupabaseClient) UpdateKeyStatus(ownerID int64, encryptedKey, status, checkedAt string) (bool, error) {
+	payload := map[string]interface{}{"key_status": status, "key_checked_at": checkedAt}
+	return s.patchUserIfKey("update key status", ownerID, encryptedKey, payload)
+}
+
+// ReplaceEncryptedKey hanya mengubah encrypted_groq_key, dengan syarat nilainya masih oldKey.
+func (s *SupabaseClient) ReplaceEncryptedKey(ownerID int64, oldKey, newKey string) (bool, error) {
+	payload := map[string]interface{}{"encrypted_groq_key": newKey}
+	return s.patchUserIfKey("replace encrypted key", ownerID, oldKey, payload)
+}
+
+// patchUserIfKey menulis payload ke baris user hanya jika encrypted_groq_key masih encryptedKey.
+// PostgREST mengembalikan baris yang berubah, jadi hasil kosong berarti key sudah diganti.
+func (s *SupabaseClient) patchUserIfKey(op string, ownerID int64, encryptedKey string, payload map[string]interface{}) (bool, error) {
+	url := fmt.Sprintf("%s/rest/v1/users?telegram_id=eq.%d&encrypted_groq_key=eq.%s&select=telegram_id", s.URL, ownerID, url.QueryEscape(encryptedKey))
+	var rows []struct {
+		TelegramID int64 `json:"telegram_id"`
+	}
+	if err := s.do(op, "PATCH", url, payload, "return=representation", &rows); err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+// usersPageSize adalah jumlah user per permintaan ListUsers. PostgREST memotong hasil di
+// max-rows tanpa error, jadi halaman dibaca terus sampai kosong (max-rows bisa lebih kecil dari ini).
+const usersPageSize = 500
+
+func (s *SupabaseClient) ListUsers() ([]models.User, error) {
+	var users []models.User
+	for {
+		url := fmt.Sprintf("%s/rest/v1/users?select=*&order=telegram_id&limit=%d&offset=%d", s.URL, usersPageSize, len(users))
+		var page []models.User
+		if err := s.do("list users", "GET", url, nil, "", &page); err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			return users, nil
+		}
+		users = append(users, page...)
+	}
 }
 
-func (s *SupabaseClient) SaveMessage(ownerID, customerID int64, customerName, role, content string) {
+func (s *SupabaseClient) SaveMessage(ownerID, customerID int64, customerName, role, content string) error {
 	url := fmt.Sprintf("%s/rest/v1/messages", s.URL)
 	payload := map[string]interface{}{"owner_id": ownerID, "customer_id": customerID, "customer_name": customerName, "role": role, "content": content}
-	jsonData, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("apikey", s.Key)
-	req.Header.Set("Authorization", "Bearer "+s.Key)
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err == nil { defer resp.Body.Close() }
+	return s.do("save message", "POST", url, payload, "return=minimal", nil)
 }
 
-func (s *SupabaseClient) GetChatHistory(ownerID, customerID int64) []models.ChatMessage {
-	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&customer_id=eq.%d&order=created_at.desc&limit=10", s.URL, ownerID, customerID)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("apikey", s.Key)
-	req.Header.Set("Authorization", "Bearer "+s.Key)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil { return nil }
-	defer resp.Body.Close()
+func (s *SupabaseClient) GetChatHistory(ownerID, customerID int64, limit int) ([]models.ChatMessage, error) {
+	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&customer_id=eq.%d&order=created_at.desc&limit=%d", s.URL, ownerID, customerID, limit)
 	var rawMsgs []models.ChatMessage
-	body, _ := io.ReadAll(resp.Body)
-	json.Unmarshal(body, &rawMsgs)
+	if err := s.do("get chat history", "GET", url, nil, "", &rawMsgs); err != nil {
+		return nil, err
+	}
 	var history []models.ChatMessage
 	for i := len(rawMsgs) - 1; i >= 0; i-- { history = append(history, rawMsgs[i]) }
-	return history
+	return history, nil
 }
 
-func (s *SupabaseClient) GetBusinessCustomers(ownerID int64) []map[string]interface{} {
-	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&select=customer_id,customer_name", s.URL, ownerID)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("apikey", s.Key)
-	req.Header.Set("Authorization", "Bearer "+s.Key)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil { return nil }
-	defer resp.Body.Close()
+func (s *SupabaseClient) CountMessages(ownerID, customerID int64) (int, error) {
+	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&customer_id=eq.%d&select=id", s.URL, ownerID, customerID)
+	return s.count("count messages", url)
+}
 
+func (s *SupabaseClient) GetMessages(ownerID, customerID int64, offset, limit int) ([]models.ChatMessage, error) {
+	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&customer_id=eq.%d&order=created_at.asc&offset=%d&limit=%d", s.URL, ownerID, customerID, offset, limit)
+	var msgs []models.ChatMessage
+	if err := s.do("get messages", "GET", url, nil, "", &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (s *SupabaseClient) GetBusinessCustomers(ownerID int64) ([]map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&select=customer_id,customer_name", s.URL, ownerID)
 	var results []map[string]interface{}
-	body, _ := io.ReadAll(resp.Body)
-	json.Unmarshal(body, &results)
-	
+	if err := s.do("get business customers", "GET", url, nil, "", &results); err != nil {
+		return nil, err
+	}
+
 	uniqueCustomers := make(map[int64]string)
 	for _, entry := range results {
 		var cid int64
@@ -120,29 +222,195 @@ func (s *SupabaseClient) GetBusinessCustomers(ownerID int64) []map[string]interf
 		}
 
 		name, _ := entry["customer_name"].(string)
-		if cid != 0 {
-			// Prioritaskan nama yang bukan "Unknown" atau kosong
-			if name != "" && name != "Unknown" {
-				uniqueCustomers[cid] = name
-			} else if _, exists := uniqueCustomers[cid]; !exists {
-				uniqueCustomers[cid] = fmt.Sprintf("User %d", cid)
-			}
-		}
+		addCustomer(uniqueCustomers, cid, name)
 	}
+	return customerList(uniqueCustomers), nil
+}
 
-	var list []map[string]interface{}
-	for id, name := range uniqueCustomers {
-		list = append(list, map[string]interface{}{"customer_id": id, "customer_name": name})
+func (s *SupabaseClient) ClearHistoryPerUser(ownerID, customerID int64) error {
+	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&customer_id=eq.%d", s.URL, ownerID, customerID)
+	if err := s.do("clear history", "DELETE", url, nil, "", nil); err != nil {
+		return err
 	}
-	return list
+	url = fmt.Sprintf("%s/rest/v1/conversation_summaries?owner_id=eq.%d&customer_id=eq.%d", s.URL, ownerID, customerID)
+	return s.do("clear history", "DELETE", url, nil, "", nil)
 }
 
-func (s *SupabaseClient) ClearHistoryPerUser(ownerID, customerID int64) {
-	url := fmt.Sprintf("%s/rest/v1/messages?owner_id=eq.%d&customer_id=eq.%d", s.URL, ownerID, customerID)
-	req, _ := http.NewRequest("DELETE", url, nil)
-	req.Header.Set("apikey", s.Key)
-	req.Header.Set("Authorization", "Bearer "+s.Key)
-	client := &http.Client{}
-	resp, _ := client.Do(req)
-	if resp != nil { defer resp.Body.Close() }
-}
\ No newline at end of file
+func (s *SupabaseClient) GetSummary(ownerID, customerID int64) (*models.ConversationSummary, error) {
+	url := fmt.Sprintf("%s/rest/v1/conversation_summaries?owner_id=eq.%d&customer_id=eq.%d&select=*", s.URL, ownerID, customerID)
+	var summaries []models.ConversationSummary
+	if err := s.do("get summary", "GET", url, nil, "", &summaries); err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, newError("get summary", ErrNotFound, nil)
+	}
+	return &summaries[0], nil
+}
+
+func (s *SupabaseClient) SaveSummary(summary models.ConversationSummary) error {
+	url := fmt.Sprintf("%s/rest/v1/conversation_summaries", s.URL)
+	return s.do("save summary", "POST", url, summary, "resolution=merge-duplicates,return=minimal", nil)
+}
+
+func (s *SupabaseClient) GetChatPause(ownerID, customerID int64) (*models.ChatPause, error) {
+	url := fmt.Sprintf("%s/rest/v1/chat_pauses?owner_id=eq.%d&customer_id=eq.%d&select=*", s.URL, ownerID, customerID)
+	var pauses []models.ChatPause
+	if err := s.do("get chat pause", "GET", url, nil, "", &pauses); err != nil {
+		return nil, err
+	}
+	if len(pauses) == 0 {
+		return nil, newError("get chat pause", ErrNotFound, nil)
+	}
+	return &pauses[0], nil
+}
+
+func (s *SupabaseClient) SaveChatPause(pause models.ChatPause) error {
+	url := fmt.Sprintf("%s/rest/v1/chat_pauses", s.URL)
+	return s.do("save chat pause", "POST", url, pause, "resolution=merge-duplicates,return=minimal", nil)
+}
+
+func (s *SupabaseClient) DeleteChatPause(ownerID, customerID int64) error {
+	url := fmt.Sprintf("%s/rest/v1/chat_pauses?owner_id=eq.%d&customer_id=eq.%d", s.URL, ownerID, customerID)
+	return s.do("delete chat pause", "DELETE", url, nil, "", nil)
+}
+
+func (s *SupabaseClient) ListChatPauses(ownerID int64, now time.Time) ([]models.ChatPause, error) {
+	url := fmt.Sprintf("%s/rest/v1/chat_pauses?owner_id=eq.%d&paused_until=gt.%s&order=paused_until&select=*", s.URL, ownerID, now.UTC().Format(time.RFC3339))
+	var pauses []models.ChatPause
+	if err := s.do("list chat pauses", "GET", url, nil, "", &pauses); err != nil {
+		return nil, err
+	}
+	return pauses, nil
+}
+
+// SaveKnowledgeDocument menyimpan baris dokumen lalu semua potongannya dalam satu bulk insert.
+// PostgREST tidak punya transaksi lintas request, jadi dokumen dihapus lagi jika potongannya gagal.
+func (s *SupabaseClient) SaveKnowledgeDocument(doc models.KnowledgeDocument, chunks []string) (int64, error) {
+	url := fmt.Sprintf("%s/rest/v1/knowledge_documents", s.URL)
+	doc.ID = 0
+	doc.ChunkCount = len(chunks)
+	var saved []models.KnowledgeDocument
+	if err := s.do("save knowledge document", "POST", url, doc, "return=representation", &saved); err != nil {
+		return 0, err
+	}
+	if len(saved) == 0 {
+		return 0, newError("save knowledge document", ErrInvalid, fmt.Errorf("no row returned"))
+	}
+	id := saved[0].ID
+
+	rows := make([]models.KnowledgeChunk, len(chunks))
+	for i, content := range chunks {
+		rows[i] = models.KnowledgeChunk{DocumentID: id, OwnerID: doc.OwnerID, Position: i, Content: content}
+	}
+	url = fmt.Sprintf("%s/rest/v1/knowledge_chunks", s.URL)
+	if err := s.do("save knowledge document", "POST", url, rows, "return=minimal", nil); err != nil {
+		s.DeleteKnowledgeDocument(doc.OwnerID, id)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *SupabaseClient) ListKnowledgeDocuments(ownerID int64) ([]models.KnowledgeDocument, error) {
+	url := fmt.Sprintf("%s/rest/v1/knowledge_documents?owner_id=eq.%d&order=id&select=*", s.URL, ownerID)
+	var docs []models.KnowledgeDocument
+	if err := s.do("list knowledge documents", "GET", url, nil, "", &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (s *SupabaseClient) DeleteKnowledgeDocument(ownerID, documentID int64) error {
+	url := fmt.Sprintf("%s/rest/v1/knowledge_chunks?owner_id=eq.%d&document_id=eq.%d", s.URL, ownerID, documentID)
+	if err := s.do("delete knowledge document", "DELETE", url, nil, "", nil); err != nil {
+		return err
+	}
+	url = fmt.Sprintf("%s/rest/v1/knowledge_documents?owner_id=eq.%d&id=eq.%d", s.URL, ownerID, documentID)
+	return s.do("delete knowledge document", "DELETE", url, nil, "", nil)
+}
+
+func (s *SupabaseClient) ListKnowledgeChunks(ownerID int64) ([]models.KnowledgeChunk, error) {
+	url := fmt.Sprintf("%s/rest/v1/knowledge_chunks?owner_id=eq.%d&order=document_id,position&select=*", s.URL, ownerID)
+	var chunks []models.KnowledgeChunk
+	if err := s.do("list knowledge chunks", "GET", url, nil, "", &chunks); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+func (s *SupabaseClient) GetDialogState(telegramID int64) (*models.DialogState, error) {
+	url := fmt.Sprintf("%s/rest/v1/dialog_states?telegram_id=eq.%d&select=*", s.URL, telegramID)
+	var states []models.DialogState
+	if err := s.do("get dialog state", "GET", url, nil, "", &states); err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, newError("get dialog state", ErrNotFound, nil)
+	}
+	return &states[0], nil
+}
+
+func (s *SupabaseClient) SaveDialogState(state models.DialogState) error {
+	url := fmt.Sprintf("%s/rest/v1/dialog_states", s.URL)
+	return s.do("save dialog state", "POST", url, state, "resolution=merge-duplicates,return=minimal", nil)
+}
+
+func (s *SupabaseClient) ClearDialogState(telegramID int64) error {
+	url := fmt.Sprintf("%s/rest/v1/dialog_states?telegram_id=eq.%d", s.URL, telegramID)
+	return s.do("clear dialog state", "DELETE", url, nil, "", nil)
+}
+
+func (s *SupabaseClient) ListExpiredDialogStates(now time.Time) ([]models.DialogState, error) {
+	url := fmt.Sprintf("%s/rest/v1/dialog_states?expires_at=lt.%s&select=*", s.URL, now.UTC().Format(time.RFC3339))
+	var states []models.DialogState
+	if err := s.do("list expired dialog states", "GET", url, nil, "", &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (s *SupabaseClient) RecordUsage(rec models.UsageRecord) error {
+	url := fmt.Sprintf("%s/rest/v1/ai_usage", s.URL)
+	return s.do("record usage", "POST", url, rec, "return=minimal", nil)
+}
+
+func (s *SupabaseClient) ListUsage(ownerID int64, since time.Time) ([]models.UsageRecord, error) {
+	url := fmt.Sprintf("%s/rest/v1/ai_usage?owner_id=eq.%d&created_at=gte.%s&order=created_at&select=*", s.URL, ownerID, since.UTC().Format(time.RFC3339))
+	var records []models.UsageRecord
+	if err := s.do("list usage", "GET", url, nil, "", &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// SumUsage memanggil fungsi usage_totals (lihat supabase/migrations) yang menjumlahkan di Postgres.
+func (s *SupabaseClient) SumUsage(ownerID int64, since time.Time) (models.UsageTotals, error) {
+	url := fmt.Sprintf("%s/rest/v1/rpc/usage_totals", s.URL)
+	payload := map[string]interface{}{"p_owner_id": ownerID, "p_since": since.UTC().Format(time.RFC3339)}
+	var rows []models.UsageTotals
+	if err := s.do("sum usage", "POST", url, payload, "", &rows); err != nil {
+		return models.UsageTotals{}, err
+	}
+	if len(rows) == 0 { return models.UsageTotals{}, nil }
+	return rows[0], nil
+}
+
+// GetUpdateOffset membaca offset getUpdates terakhir yang tersimpan (0 jika belum ada).
+func (s *SupabaseClient) GetUpdateOffset() (int64, error) {
+	url := fmt.Sprintf("%s/rest/v1/bot_state?key=eq.update_offset&select=value", s.URL)
+	var rows []struct {
+		Value int64 `json:"value"`
+	}
+	if err := s.do("get update offset", "GET", url, nil, "", &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 { return 0, nil }
+	return rows[0].Value, nil
+}
+
+// SaveUpdateOffset menyimpan offset getUpdates supaya proses yang di-restart melanjutkan dari sini.
+func (s *SupabaseClient) SaveUpdateOffset(offset int64) error {
+	url := fmt.Sprintf("%s/rest/v1/bot_state", s.URL)
+	payload := map[string]interface{}{"key": "update_offset", "value": offset}
+	return s.do("save update offset", "POST", url, payload, "resolution=merge-duplicates,return=minimal", nil)
+}
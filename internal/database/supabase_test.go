@@ -0,0 +1,47 @@
+package database
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountMessagesReadsContentRange(t *testing.T) {
+	var method, prefer, query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, prefer, query = r.Method, r.Header.Get("Prefer"), r.URL.RawQuery
+		w.Header().Set("Content-Range", "0-24/137")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	n, err := NewSupabaseClient(srv.URL, "key").CountMessages(1, 2)
+	if err != nil || n != 137 {
+		t.Fatalf("CountMessages = %d, %v; want 137", n, err)
+	}
+	if method != "HEAD" || prefer != "count=exact" {
+		t.Errorf("request = %s with Prefer %q, want HEAD with count=exact", method, prefer)
+	}
+	if query != "owner_id=eq.1&customer_id=eq.2&select=id" {
+		t.Errorf("query = %q", query)
+	}
+}
+
+func TestCountMessagesEmptyAndInvalidRange(t *testing.T) {
+	contentRange := "*/0"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", contentRange)
+	}))
+	defer srv.Close()
+	db := NewSupabaseClient(srv.URL, "key")
+
+	if n, err := db.CountMessages(1, 2); err != nil || n != 0 {
+		t.Fatalf("CountMessages = %d, %v; want 0", n, err)
+	}
+
+	contentRange = ""
+	if _, err := db.CountMessages(1, 2); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid", err)
+	}
+}
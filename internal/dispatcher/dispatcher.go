@@ -0,0 +1,126 @@
+package dispatcher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"tg-business-bot/internal/api"
+)
+
+// Dispatcher membagi update ke sejumlah worker. Update dengan kunci percakapan yang sama
+// selalu masuk ke worker yang sama sehingga urutannya terjaga, sedangkan percakapan
+// yang berbeda diproses paralel.
+type Dispatcher struct {
+	queues []chan api.Update
+	handle func(api.Update)
+	wg     sync.WaitGroup
+
+	// Pelacakan update yang belum selesai untuk menghitung offset yang aman disimpan
+	mu      sync.Mutex
+	pending map[int64]struct{}
+	next    int64
+}
+
+func NewDispatcher(workers, queueSize int, handle func(api.Update)) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	d := &Dispatcher{
+		queues:  make([]chan api.Update, workers),
+		handle:  handle,
+		pending: make(map[int64]struct{}),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan api.Update, queueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// Submit memasukkan update ke antrean worker-nya. Jika antrean penuh, Submit menunggu
+// sampai ada slot kosong sehingga sumber update (polling/webhook) ikut melambat.
+func (d *Dispatcher) Submit(update api.Update) {
+	d.mu.Lock()
+	d.pending[update.UpdateID] = struct{}{}
+	if update.UpdateID+1 > d.next {
+		d.next = update.UpdateID + 1
+	}
+	d.mu.Unlock()
+
+	d.queues[d.workerFor(routingKey(update))] <- update
+}
+
+// Committed mengembalikan offset getUpdates berikutnya yang aman disimpan: semua update
+// dengan ID di bawahnya sudah selesai diproses. Karena worker berjalan paralel, nilai ini
+// bisa tertinggal dari update terakhir yang diterima selama masih ada yang diproses.
+func (d *Dispatcher) Committed() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	committed := d.next
+	for id := range d.pending {
+		if id < committed {
+			committed = id
+		}
+	}
+	return committed
+}
+
+// Stop menutup semua antrean dan menunggu sampai update yang tersisa selesai diproses.
+// Submit tidak boleh dipanggil lagi setelah Stop.
+func (d *Dispatcher) Stop() {
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) work(queue chan api.Update) {
+	defer d.wg.Done()
+	for update := range queue {
+		d.process(update)
+	}
+}
+
+func (d *Dispatcher) process(update api.Update) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.pending, update.UpdateID)
+		d.mu.Unlock()
+	}()
+	// Panic di satu handler tidak boleh mematikan worker beserta seluruh antreannya
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Dispatcher Error: Panic while handling update %d: %v", update.UpdateID, r)
+		}
+	}()
+	d.handle(update)
+}
+
+func (d *Dispatcher) workerFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+// routingKey menentukan percakapan pemilik update. Pesan bisnis dikunci per koneksi bisnis
+// dan chat pelanggan; aktivitas dashboard dan koneksi dikunci per owner.
+func routingKey(update api.Update) string {
+	switch {
+	case update.BusinessMessage != nil && update.BusinessMessage.Chat != nil:
+		return fmt.Sprintf("biz:%s:%d", update.BusinessMessage.BusinessConnectionID, update.BusinessMessage.Chat.ID)
+	case update.BusinessConnection != nil:
+		return fmt.Sprintf("user:%d", update.BusinessConnection.UserChatID)
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return fmt.Sprintf("user:%d", update.CallbackQuery.From.ID)
+	case update.Message != nil && update.Message.From != nil:
+		return fmt.Sprintf("user:%d", update.Message.From.ID)
+	}
+	return fmt.Sprintf("update:%d", update.UpdateID)
+}
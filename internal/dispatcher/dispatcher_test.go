@@ -0,0 +1,139 @@
+package dispatcher
+
+import (
+	"sync"
+	"testing"
+	"tg-business-bot/internal/api"
+	"time"
+)
+
+func businessUpdate(id, chatID int64) api.Update {
+	return api.Update{
+		UpdateID: id,
+		BusinessMessage: &api.Message{
+			BusinessConnectionID: "conn",
+			Chat:                 &api.Chat{ID: chatID},
+		},
+	}
+}
+
+func TestDispatcherKeepsOrderPerChat(t *testing.T) {
+	const chats, perChat = 8, 50
+
+	var mu sync.Mutex
+	seen := make(map[int64][]int64)
+	var done sync.WaitGroup
+	done.Add(chats * perChat)
+
+	d := NewDispatcher(4, 4, func(u api.Update) {
+		defer done.Done()
+		// Jeda kecil supaya worker benar-benar berjalan bersamaan
+		time.Sleep(time.Microsecond)
+		mu.Lock()
+		seen[u.BusinessMessage.Chat.ID] = append(seen[u.BusinessMessage.Chat.ID], u.UpdateID)
+		mu.Unlock()
+	})
+
+	id := int64(1)
+	for i := 0; i < perChat; i++ {
+		for chat := int64(1); chat <= chats; chat++ {
+			d.Submit(businessUpdate(id, chat))
+			id++
+		}
+	}
+	done.Wait()
+
+	for chat, ids := range seen {
+		if len(ids) != perChat {
+			t.Errorf("chat %d: handled %d updates, want %d", chat, len(ids), perChat)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("chat %d: update %d handled after %d", chat, ids[i], ids[i-1])
+			}
+		}
+	}
+}
+
+func TestDispatcherRecoversFromPanic(t *testing.T) {
+	handled := make(chan int64, 2)
+	d := NewDispatcher(1, 2, func(u api.Update) {
+		if u.UpdateID == 1 {
+			panic("boom")
+		}
+		handled <- u.UpdateID
+	})
+
+	d.Submit(businessUpdate(1, 7))
+	d.Submit(businessUpdate(2, 7))
+
+	select {
+	case id := <-handled:
+		if id != 2 {
+			t.Fatalf("handled update %d, want 2", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker stopped after a handler panic")
+	}
+}
+
+func TestCommittedWaitsForUnfinishedUpdates(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan int64, 3)
+	d := NewDispatcher(2, 4, func(u api.Update) {
+		// Update 10 tertahan, update lain di chat berbeda selesai lebih dulu
+		if u.UpdateID == 10 {
+			<-release
+		}
+		finished <- u.UpdateID
+	})
+
+	if got := d.Committed(); got != 0 {
+		t.Fatalf("Committed before any update = %d, want 0", got)
+	}
+
+	d.Submit(businessUpdate(10, 1))
+	for _, id := range []int64{11, 12} {
+		// Cari chat yang jatuh ke worker lain supaya update ini tidak menunggu update 10
+		chat := int64(2)
+		for d.workerFor(routingKey(businessUpdate(id, chat))) == d.workerFor(routingKey(businessUpdate(10, 1))) {
+			chat++
+		}
+		d.Submit(businessUpdate(id, chat))
+	}
+	for i := 0; i < 2; i++ {
+		<-finished
+	}
+	if got := d.Committed(); got != 10 {
+		t.Fatalf("Committed with update 10 still running = %d, want 10", got)
+	}
+
+	close(release)
+	<-finished
+	d.Stop()
+	if got := d.Committed(); got != 13 {
+		t.Fatalf("Committed after all updates = %d, want 13", got)
+	}
+}
+
+func TestStopDrainsQueue(t *testing.T) {
+	var mu sync.Mutex
+	handled := 0
+	d := NewDispatcher(2, 16, func(api.Update) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+	for id := int64(1); id <= 20; id++ {
+		d.Submit(businessUpdate(id, id%3))
+	}
+	d.Stop()
+
+	if handled != 20 {
+		t.Fatalf("handled %d updates before Stop returned, want 20", handled)
+	}
+	if got := d.Committed(); got != 21 {
+		t.Fatalf("Committed after Stop = %d, want 21", got)
+	}
+}
@@ -10,6 +10,12 @@ import (
 )
 
 func Encrypt(plainText string, key string) (string, error) {
+	return EncryptWithAD(plainText, key, nil)
+}
+
+// EncryptWithAD seperti Encrypt, tetapi ciphertext diikat ke additionalData (AEAD). Data yang
+// sama harus diberikan lagi ke DecryptWithAD, kalau tidak dekripsi gagal.
+func EncryptWithAD(plainText string, key string, additionalData []byte) (string, error) {
 	block, err := aes.NewCipher([]byte(key))
 	if err != nil {
 		return "", err
@@ -25,11 +31,15 @@ func Encrypt(plainText string, key string) (string, error) {
 		return "", err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plainText), nil)
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plainText), additionalData)
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
 func Decrypt(cryptoText string, key string) (string, error) {
+	return DecryptWithAD(cryptoText, key, nil)
+}
+
+func DecryptWithAD(cryptoText string, key string, additionalData []byte) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(cryptoText)
 	if err != nil {
 		return "", err
@@ -51,7 +61,7 @@ func Decrypt(cryptoText string, key string) (string, error) {
 	}
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plainText, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plainText, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,188 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Cara mengubah secret operator menjadi key AES-256.
+const (
+	KDFArgon2 = "argon2id" // passphrase dengan panjang berapa pun diturunkan lewat Argon2id
+	KDFNone   = "none"     // secret dipakai apa adanya dan wajib tepat 32 byte (perilaku lama)
+)
+
+// Parameter Argon2id mengikuti rekomendasi RFC 9106 untuk lingkungan dengan memori terbatas.
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	keySize      = 32
+)
+
+// KeyProvider menyediakan data key AES-256 untuk satu key ID. Implementasinya menentukan dari
+// mana material key berasal: env var, file, atau KMS dengan envelope encryption.
+type KeyProvider interface {
+	DataKey(keyID string) ([]byte, error)
+}
+
+// MinSaltSize adalah panjang minimum salt Argon2id per deployment.
+const MinSaltSize = 16
+
+// ErrMissingSalt dikembalikan jika Argon2id dipakai tanpa salt per deployment.
+var ErrMissingSalt = fmt.Errorf("argon2id requires a random per-deployment salt of at least %d bytes", MinSaltSize)
+
+// DeriveKey menurunkan key 32 byte dari secret sesuai kdf. Untuk Argon2id, salt acak milik
+// deployment digabung dengan key ID, sehingga passphrase yang sama menghasilkan key berbeda di
+// setiap deployment dan untuk setiap key ID.
+func DeriveKey(secret string, salt []byte, keyID, kdf string) ([]byte, error) {
+	switch kdf {
+	case KDFNone:
+		if len(secret) != keySize {
+			return nil, fmt.Errorf("key %q must be exactly %d bytes when no KDF is used, got %d", keyID, keySize, len(secret))
+		}
+		return []byte(secret), nil
+	case KDFArgon2:
+		if secret == "" {
+			return nil, fmt.Errorf("passphrase for key %q is empty", keyID)
+		}
+		if len(salt) < MinSaltSize {
+			return nil, ErrMissingSalt
+		}
+		keySalt := append(append(append([]byte{}, salt...), '/'), keyID...)
+		return argon2.IDKey([]byte(secret), keySalt, argonTime, argonMemory, argonThreads, keySize), nil
+	default:
+		return nil, fmt.Errorf("unknown KDF %q", kdf)
+	}
+}
+
+// GenerateSalt membuat salt acak untuk ENCRYPTION_KEY_SALT.
+func GenerateSalt() ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DefaultKDF memilih KDF untuk secret yang tidak menyebutkannya: secret 32 byte dianggap key
+// mentah dari versi lama, selain itu passphrase yang diturunkan dengan Argon2id.
+func DefaultKDF(secret string) string {
+	if len(secret) == keySize {
+		return KDFNone
+	}
+	return KDFArgon2
+}
+
+// PassphraseProvider menurunkan data key dari passphrase yang dibaca dari env var atau file.
+type PassphraseProvider struct {
+	Passphrase string
+	KDF        string
+	Salt       []byte
+}
+
+func NewEnvProvider(envVar, kdf string, salt []byte) (*PassphraseProvider, error) {
+	passphrase := os.Getenv(envVar)
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s is empty", envVar)
+	}
+	return newPassphraseProvider(passphrase, kdf, salt), nil
+}
+
+// NewFileProvider membaca passphrase dari file (mis. secret yang di-mount container).
+// Spasi dan newline di ujung file diabaikan.
+func NewFileProvider(path, kdf string, salt []byte) (*PassphraseProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	passphrase := strings.TrimSpace(string(data))
+	if passphrase == "" {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+	return newPassphraseProvider(passphrase, kdf, salt), nil
+}
+
+func newPassphraseProvider(passphrase, kdf string, salt []byte) *PassphraseProvider {
+	if kdf == "" {
+		kdf = DefaultKDF(passphrase)
+	}
+	return &PassphraseProvider{Passphrase: passphrase, KDF: kdf, Salt: salt}
+}
+
+func (p *PassphraseProvider) DataKey(keyID string) ([]byte, error) {
+	return DeriveKey(p.Passphrase, p.Salt, keyID, p.KDF)
+}
+
+// KMS membungkus dan membuka data key dengan master key yang tidak pernah keluar dari KMS.
+// Data key terikat ke key ID-nya, jadi data key terbungkus tidak bisa dipakai dengan ID lain.
+type KMS interface {
+	Wrap(dataKey []byte, keyID string) (string, error)
+	Unwrap(wrappedKey, keyID string) ([]byte, error)
+}
+
+// EnvelopeProvider menyimpan data key dalam bentuk terbungkus; hanya KMS yang bisa membukanya.
+type EnvelopeProvider struct {
+	KMS        KMS
+	WrappedKey string
+}
+
+func (p *EnvelopeProvider) DataKey(keyID string) ([]byte, error) {
+	return unwrapDataKey(p.KMS, p.WrappedKey, keyID)
+}
+
+func unwrapDataKey(kms KMS, wrappedKey, keyID string) ([]byte, error) {
+	if wrappedKey == "" {
+		return nil, errors.New("wrapped data key is empty")
+	}
+	dataKey, err := kms.Unwrap(wrappedKey, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key %q: %w", keyID, err)
+	}
+	if len(dataKey) != keySize {
+		return nil, fmt.Errorf("unwrapped data key %q has %d bytes, want %d", keyID, len(dataKey), keySize)
+	}
+	return dataKey, nil
+}
+
+// GenerateDataKey membuat data key acak baru untuk keyID dan mengembalikannya dalam bentuk terbungkus.
+func GenerateDataKey(kms KMS, keyID string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	return kms.Wrap(dataKey, keyID)
+}
+
+// LocalKMS adalah pengganti KMS untuk development dan self-hosting: master key diturunkan
+// dari passphrase lokal dan data key dibungkus dengan AES-GCM.
+type LocalKMS struct {
+	masterKey []byte
+}
+
+func NewLocalKMS(passphrase string, salt []byte) (*LocalKMS, error) {
+	masterKey, err := DeriveKey(passphrase, salt, "kms-master", KDFArgon2)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKMS{masterKey: masterKey}, nil
+}
+
+// Wrap membungkus data key dengan key ID sebagai associated data.
+func (k *LocalKMS) Wrap(dataKey []byte, keyID string) (string, error) {
+	return EncryptWithAD(base64.StdEncoding.EncodeToString(dataKey), string(k.masterKey), []byte("data-key:"+keyID))
+}
+
+// Unwrap membuka data key terbungkus; hanya berhasil dengan key ID yang sama seperti saat dibungkus.
+func (k *LocalKMS) Unwrap(wrappedKey, keyID string) ([]byte, error) {
+	encoded, err := DecryptWithAD(wrappedKey, string(k.masterKey), []byte("data-key:"+keyID))
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
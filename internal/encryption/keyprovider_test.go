@@ -0,0 +1,92 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var testSalt = []byte("0123456789abcdef")
+
+func TestDeriveKeySalt(t *testing.T) {
+	if _, err := DeriveKey("passphrase", nil, "k1", KDFArgon2); !errors.Is(err, ErrMissingSalt) {
+		t.Fatalf("DeriveKey without salt = %v, want ErrMissingSalt", err)
+	}
+	if _, err := DeriveKey("passphrase", testSalt[:MinSaltSize-1], "k1", KDFArgon2); !errors.Is(err, ErrMissingSalt) {
+		t.Fatalf("DeriveKey with a short salt = %v, want ErrMissingSalt", err)
+	}
+
+	a, err := DeriveKey("passphrase", testSalt, "k1", KDFArgon2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := DeriveKey("passphrase", testSalt, "k1", KDFArgon2)
+	otherSalt, _ := DeriveKey("passphrase", []byte("fedcba9876543210"), "k1", KDFArgon2)
+	otherID, _ := DeriveKey("passphrase", testSalt, "k2", KDFArgon2)
+	if !bytes.Equal(a, again) {
+		t.Error("DeriveKey is not deterministic")
+	}
+	if bytes.Equal(a, otherSalt) || bytes.Equal(a, otherID) {
+		t.Error("salt and key ID do not change the derived key")
+	}
+
+	// Key mentah dari versi lama tidak butuh salt
+	if _, err := DeriveKey(testOldKey, nil, "k1", KDFNone); err != nil {
+		t.Errorf("DeriveKey with KDFNone: %v", err)
+	}
+}
+
+func TestLocalKMSBindsKeyID(t *testing.T) {
+	if _, err := NewLocalKMS("test passphrase", nil); !errors.Is(err, ErrMissingSalt) {
+		t.Fatalf("NewLocalKMS without salt = %v, want ErrMissingSalt", err)
+	}
+	kms, err := NewLocalKMS("test passphrase", testSalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := GenerateDataKey(kms, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&EnvelopeProvider{KMS: kms, WrappedKey: wrapped}).DataKey("k1"); err != nil {
+		t.Errorf("DataKey with the wrapping key ID: %v", err)
+	}
+	if _, err := (&EnvelopeProvider{KMS: kms, WrappedKey: wrapped}).DataKey("k2"); err == nil {
+		t.Error("data key wrapped for k1 was unwrapped as k2")
+	}
+}
+
+func TestAddOldKeysWrapped(t *testing.T) {
+	kms, err := NewLocalKMS("test passphrase", testSalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := kms.Wrap([]byte(testOldKey), "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewKeyring(&PassphraseProvider{Passphrase: testNewKey, KDF: KDFNone}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.AddOldKeys("k1:wrapped:"+wrapped, nil, kms); err != nil {
+		t.Fatal(err)
+	}
+	cipherText, err := EncryptWithAD("secret", testOldKey, []byte(Binding(1, "api_key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := keys.Decrypt("v2:k1:"+cipherText, Binding(1, "api_key")); err != nil || got != "secret" {
+		t.Errorf("Decrypt with a wrapped old key = %q, %v", got, err)
+	}
+
+	// Data key terbungkus untuk k1 tidak boleh dipasang dengan ID lain
+	other, err := NewKeyring(&PassphraseProvider{Passphrase: testNewKey, KDF: KDFNone}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.AddOldKeys("k3:wrapped:"+wrapped, nil, kms); err == nil {
+		t.Error("wrapped data key was accepted under another key ID")
+	}
+}
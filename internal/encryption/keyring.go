@@ -0,0 +1,191 @@
+package encryption
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Format ciphertext berversi:
+//   - "v2:<keyID>:<base64>" terikat ke pemilik lewat associated data (lihat Binding),
+//   - "v1:<keyID>:<base64>" tanpa associated data (sebelum pengikatan),
+//   - base64 murni dari versi paling awal; alfabet base64 tidak memuat ':' jadi tidak bentrok.
+const (
+	boundPrefix   = "v2:"
+	versionPrefix = "v1:"
+)
+
+// wrappedOldKey menandai key lama di ENCRYPTION_OLD_KEYS yang berupa data key terbungkus KMS.
+const wrappedOldKey = "wrapped:"
+
+var (
+	ErrUnknownKeyID = errors.New("ciphertext was encrypted with an unknown key ID")
+	ErrUnbound      = errors.New("ciphertext is not bound to its owner")
+)
+
+// Binding mengembalikan associated data untuk secret milik satu user, misalnya
+// Binding(123, "api_key"). Ciphertext yang disalin ke baris user lain gagal didekripsi.
+func Binding(telegramID int64, field string) string {
+	return fmt.Sprintf("user:%d:%s", telegramID, field)
+}
+
+// Keyring menyimpan satu key utama untuk enkripsi dan beberapa key lama yang hanya dipakai
+// untuk dekripsi selama masa rotasi.
+type Keyring struct {
+	primaryID string
+	keys      map[string]string
+	// order adalah urutan percobaan untuk ciphertext lama tanpa key ID (key utama dulu)
+	order []string
+
+	// AllowUnbound mengizinkan dekripsi ciphertext lama (v1 dan tanpa prefix) yang belum
+	// terikat ke pemilik. Defaultnya mati; hanya dinyalakan selama migrasi dan oleh "rotate-keys".
+	AllowUnbound bool
+}
+
+// NewKeyring membuat keyring dengan data key utama dari provider. Key lama ditambahkan
+// lewat AddOldKeys.
+func NewKeyring(provider KeyProvider, primaryID string) (*Keyring, error) {
+	k := &Keyring{primaryID: primaryID, keys: make(map[string]string)}
+	dataKey, err := provider.DataKey(primaryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.add(primaryID, dataKey); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// AddOldKeys menambahkan key lama khusus dekripsi dengan format "id1:secret1,id2:secret2".
+// Secret diturunkan dengan DefaultKDF, atau dengan kdf tertentu lewat "id:kdf:secret", memakai
+// salt deployment yang sama dengan key utama. Data key
+// terbungkus KMS (ENCRYPTION_WRAPPED_KEY lama) ditulis "id:wrapped:<data key terbungkus>" dan
+// dibuka lewat kms; kms boleh nil jika bentuk ini tidak dipakai.
+func (k *Keyring) AddOldKeys(spec string, salt []byte, kms KMS) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("old key %q must have the form id:key", entry)
+		}
+		id, secret := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		if strings.HasPrefix(secret, wrappedOldKey) {
+			if kms == nil {
+				return fmt.Errorf("old key %q is KMS-wrapped but ENCRYPTION_KEY_PROVIDER is not kms-local", id)
+			}
+			dataKey, err := unwrapDataKey(kms, strings.TrimPrefix(secret, wrappedOldKey), id)
+			if err != nil {
+				return err
+			}
+			if err := k.add(id, dataKey); err != nil {
+				return err
+			}
+			continue
+		}
+
+		kdf := DefaultKDF(secret)
+		for _, name := range []string{KDFArgon2, KDFNone} {
+			if strings.HasPrefix(secret, name+":") {
+				kdf, secret = name, strings.TrimPrefix(secret, name+":")
+				break
+			}
+		}
+
+		dataKey, err := DeriveKey(secret, salt, id, kdf)
+		if err != nil {
+			return err
+		}
+		if err := k.add(id, dataKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) add(id string, dataKey []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("key ID %q must be non-empty and must not contain ':'", id)
+	}
+	if len(dataKey) != keySize {
+		return fmt.Errorf("data key %q must be %d bytes, got %d", id, keySize, len(dataKey))
+	}
+	if _, exists := k.keys[id]; exists {
+		return fmt.Errorf("duplicate key ID %q", id)
+	}
+	k.keys[id] = string(dataKey)
+	k.order = append(k.order, id)
+	return nil
+}
+
+// PrimaryID mengembalikan ID key yang dipakai untuk enkripsi baru.
+func (k *Keyring) PrimaryID() string {
+	return k.primaryID
+}
+
+// Encrypt mengenkripsi dengan key utama, mengikat hasilnya ke binding, dan menyisipkan ID key.
+func (k *Keyring) Encrypt(plainText, binding string) (string, error) {
+	cipherText, err := EncryptWithAD(plainText, k.keys[k.primaryID], []byte(binding))
+	if err != nil {
+		return "", err
+	}
+	return boundPrefix + k.primaryID + ":" + cipherText, nil
+}
+
+// Decrypt membuka ciphertext terikat hanya jika binding-nya cocok. Ciphertext lama yang
+// belum terikat dibuka tanpa binding selama AllowUnbound aktif.
+func (k *Keyring) Decrypt(cryptoText, binding string) (string, error) {
+	if id, body, ok := splitVersioned(cryptoText, boundPrefix); ok {
+		key, known := k.keys[id]
+		if !known {
+			return "", fmt.Errorf("%w: %s", ErrUnknownKeyID, id)
+		}
+		return DecryptWithAD(body, key, []byte(binding))
+	}
+
+	if !k.AllowUnbound {
+		return "", ErrUnbound
+	}
+	// Ciphertext tanpa binding bisa dipindah ke owner lain; setiap pemakaian dicatat supaya
+	// operator tahu migrasi belum selesai
+	log.Printf("Warning: Decrypting an unbound secret (%s); run rotate-keys and turn off ENCRYPTION_ALLOW_UNBOUND", binding)
+
+	if id, body, ok := splitVersioned(cryptoText, versionPrefix); ok {
+		key, known := k.keys[id]
+		if !known {
+			return "", fmt.Errorf("%w: %s", ErrUnknownKeyID, id)
+		}
+		return Decrypt(body, key)
+	}
+
+	var lastErr error
+	for _, id := range k.order {
+		plainText, err := Decrypt(cryptoText, k.keys[id])
+		if err == nil {
+			return plainText, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// NeedsRotation bernilai true jika ciphertext belum terikat atau belum memakai key utama.
+func (k *Keyring) NeedsRotation(cryptoText string) bool {
+	id, _, ok := splitVersioned(cryptoText, boundPrefix)
+	return !ok || id != k.primaryID
+}
+
+func splitVersioned(cryptoText, prefix string) (id, body string, ok bool) {
+	if !strings.HasPrefix(cryptoText, prefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(cryptoText, prefix), ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"errors"
+	"testing"
+)
+
+// Key 32 byte dipakai apa adanya (KDFNone), supaya test tidak menjalankan Argon2.
+const (
+	testOldKey = "0123456789abcdef0123456789abcdef"
+	testNewKey = "fedcba9876543210fedcba9876543210"
+)
+
+// newTestKeyring membuat keyring dengan key utama "k2" dan key lama "k1" yang masih
+// menerima ciphertext belum terikat, seperti selama migrasi.
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	keys, err := NewKeyring(&PassphraseProvider{Passphrase: testNewKey, KDF: KDFNone}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.AddOldKeys("k1:"+testOldKey, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	keys.AllowUnbound = true
+	return keys
+}
+
+func mustEncrypt(t *testing.T, plainText, key string) string {
+	t.Helper()
+	cipherText, err := Encrypt(plainText, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipherText
+}
+
+func TestKeyringDecryptVersions(t *testing.T) {
+	keys := newTestKeyring(t)
+	binding := Binding(1, "api_key")
+	current, err := keys.Encrypt("secret", binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		cipherText string
+		want       string
+		wantErr    error
+	}{
+		{"v2 with primary key", current, "secret", nil},
+		{"v1 with old key", "v1:k1:" + mustEncrypt(t, "secret", testOldKey), "secret", nil},
+		{"v1 with primary key", "v1:k2:" + mustEncrypt(t, "secret", testNewKey), "secret", nil},
+		{"legacy with old key", mustEncrypt(t, "secret", testOldKey), "secret", nil},
+		{"v1 with unknown key ID", "v1:k9:" + mustEncrypt(t, "secret", testOldKey), "", ErrUnknownKeyID},
+		{"v2 with unknown key ID", "v2:k9:" + mustEncrypt(t, "secret", testOldKey), "", ErrUnknownKeyID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keys.Decrypt(tt.cipherText, binding)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decrypt = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := keys.Decrypt(mustEncrypt(t, "secret", "ffffffffffffffffffffffffffffffff"), binding); err == nil {
+		t.Error("legacy ciphertext from an unknown key was decrypted")
+	}
+	if _, err := keys.Decrypt("not base64!", binding); err == nil {
+		t.Error("garbage was decrypted")
+	}
+}
+
+func TestKeyringNeedsRotation(t *testing.T) {
+	keys := newTestKeyring(t)
+	current, err := keys.Encrypt("secret", Binding(1, "api_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		cipherText string
+		want       bool
+	}{
+		{"current", current, false},
+		{"bound to an old key", "v2:k1:abc", true},
+		{"v1 with primary key", "v1:k2:abc", true},
+		{"legacy", mustEncrypt(t, "secret", testNewKey), true},
+	}
+	for _, tt := range tests {
+		if got := keys.NeedsRotation(tt.cipherText); got != tt.want {
+			t.Errorf("%s: NeedsRotation = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddOldKeysErrors(t *testing.T) {
+	tests := []string{
+		"k1",                     // tanpa secret
+		"k2:" + testOldKey,       // bentrok dengan key utama
+		"k1:none:short",          // KDFNone wajib 32 byte
+		":" + testOldKey,         // ID kosong
+		"k1:wrapped:whatever",    // butuh KMS
+		"k1:argon2id:passphrase", // Argon2id tanpa salt deployment
+	}
+	for _, spec := range tests {
+		keys := newTestKeyring(t)
+		if err := keys.AddOldKeys(spec, nil, nil); err == nil {
+			t.Errorf("AddOldKeys(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestKeyringDecryptBinding(t *testing.T) {
+	keys := newTestKeyring(t)
+	owner := Binding(1, "api_key")
+	cipherText, err := keys.Encrypt("secret", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		binding string
+		wantErr bool
+	}{
+		{"same owner and field", owner, false},
+		{"other owner", Binding(2, "api_key"), true},
+		{"other field", Binding(1, "other"), true},
+		{"no binding", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keys.Decrypt(cipherText, tt.binding)
+			if (err != nil) != tt.wantErr || (!tt.wantErr && got != "secret") {
+				t.Errorf("Decrypt with binding %q = %q, %v", tt.binding, got, err)
+			}
+		})
+	}
+}
+
+func TestKeyringRejectsUnboundByDefault(t *testing.T) {
+	keys, err := NewKeyring(&PassphraseProvider{Passphrase: testNewKey, KDF: KDFNone}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.AddOldKeys("k1:"+testOldKey, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	binding := Binding(1, "api_key")
+
+	for _, cipherText := range []string{
+		"v1:k1:" + mustEncrypt(t, "secret", testOldKey),
+		mustEncrypt(t, "secret", testOldKey),
+	} {
+		if _, err := keys.Decrypt(cipherText, binding); !errors.Is(err, ErrUnbound) {
+			t.Errorf("Decrypt(%q) = %v, want ErrUnbound", cipherText, err)
+		}
+	}
+
+	bound, err := keys.Encrypt("secret", binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := keys.Decrypt(bound, binding); err != nil || got != "secret" {
+		t.Errorf("Decrypt of a bound secret = %q, %v", got, err)
+	}
+}
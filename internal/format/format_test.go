@@ -0,0 +1,127 @@
+package format
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "hello", "hello"},
+		{"allowed tag", "<b>bold</b>", "<b>bold</b>"},
+		{"synonym", "<strong>x</strong> <em>y</em>", "<b>x</b> <i>y</i>"},
+		{"unknown tag keeps content", "<script>alert(1)</script>", "alert(1)"},
+		{"bare special characters", "a < b & c > d", "a &lt; b &amp; c &gt; d"},
+		{"entities stay escaped", "&lt;tag&gt; &amp;", "&lt;tag&gt; &amp;"},
+		{"unclosed tag", "<b>open", "<b>open</b>"},
+		{"stray closing tag", "</i>stray", "stray"},
+		{"misnested tags", "<b><i>x</b>y", "<b><i>x</i></b>y"},
+		{"line break", "line<br>next<br/>last", "line\nnext\nlast"},
+		{"list", "<ul><li>a</li><li>b</li></ul>", "• a\n• b"},
+		{"paragraphs", "<p>one</p><p>two</p>", "one\ntwo"},
+		{"no tags inside code", "<code><b>x</b></code>", "<code>x</code>"},
+		{"code inside pre", "<pre><code>x</code></pre>", "<pre><code>x</code></pre>"},
+		{"safe link", `<a href="https://example.com/?a=1&amp;b=2">x</a>`, `<a href="https://example.com/?a=1&amp;b=2">x</a>`},
+		{"unsafe link", `<a href="javascript:alert(1)">x</a>`, "x"},
+		{"link without href", "<a>x</a>", "x"},
+		{"nested link", `<a href="https://a.com"><a href="https://b.com">x</a></a>`, `<a href="https://a.com">x</a>`},
+		{"trims whitespace", "  <br> hi \n", "hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"<b>a</b> &amp; <i>b</i>", "a & b"},
+		{"x<br>y", "x\ny"},
+		{"<b> </b>", ""},
+		{"1 < 2", "1 < 2"},
+	}
+	for _, tt := range tests {
+		if got := PlainText(tt.in); got != tt.want {
+			t.Errorf("PlainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		limit int
+		want  []string
+	}{
+		{"empty", "", 10, nil},
+		{"only tags", "<b> </b>", 10, nil},
+		{"fits", "<b>hello</b>", 10, []string{"<b>hello</b>"}},
+		{"breaks at space", "hello world again", 12, []string{"hello world", "again"}},
+		{"prefers paragraph", "aaaa bbbb\n\ncccc", 14, []string{"aaaa bbbb", "cccc"}},
+		{"reopens tags", "<b>hello world</b>", 6, []string{"<b>hello</b>", "<b>world</b>"}},
+		{"reopens link", `<a href="https://x.io">aaa bbb</a>`, 4, []string{`<a href="https://x.io">aaa</a>`, `<a href="https://x.io">bbb</a>`}},
+		{"entities count as one character", "&lt;&lt;&lt; abc", 4, []string{"&lt;&lt;&lt;", "abc"}},
+		{"hard cut without spaces", "abcdefgh", 3, []string{"abc", "def", "gh"}},
+		{"emoji counts twice", "😀😀😀", 4, []string{"😀😀", "😀"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.in, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLongReplyStaysWithinLimit(t *testing.T) {
+	in := Sanitize(strings.Repeat("<b>Menu</b> nasi goreng &amp; teh manis. ", 300))
+	parts := Split(in, MessageLimit)
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+	var joined strings.Builder
+	for i, part := range parts {
+		if n := textLen(PlainText(part)); n > MessageLimit {
+			t.Errorf("part %d has %d characters, limit %d", i, n, MessageLimit)
+		}
+		if Sanitize(part) != part {
+			t.Errorf("part %d is not balanced HTML: %q", i, part)
+		}
+		joined.WriteString(PlainText(part))
+	}
+	// Spasi di titik potong dibuang, jadi bandingkan tanpa spasi
+	noSpace := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	if got, want := noSpace(joined.String()), noSpace(PlainText(in)); got != want {
+		t.Errorf("parts lost text: got %d characters, want %d", len(got), len(want))
+	}
+}
+
+func TestSplitPlain(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int
+		want  []string
+	}{
+		{"", 5, nil},
+		{"   ", 5, nil},
+		{"one. two. three.", 10, []string{"one. two.", "three."}},
+		{"abc", 0, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		if got := SplitPlain(tt.in, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitPlain(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package format menyiapkan balasan AI untuk parse mode HTML Telegram: membersihkan tag di
+// luar whitelist, menyeimbangkan tag, dan memecah teks panjang menjadi beberapa pesan.
+package format
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MessageLimit adalah panjang maksimal teks satu pesan Telegram (setelah entity diurai).
+const MessageLimit = 4096
+
+// allowedTags memetakan tag yang diterima ke tag Telegram. Whitelist ini sama dengan yang
+// disebut di MasterHTMLPrompt; sinonim umum dari model diubah ke bentuk dasarnya.
+var allowedTags = map[string]string{
+	"b": "b", "strong": "b",
+	"i": "i", "em": "i",
+	"u": "u", "ins": "u",
+	"s": "s", "strike": "s", "del": "s",
+	"code": "code",
+	"pre":  "pre",
+	"a":    "a",
+}
+
+// allowedSchemes adalah skema link yang boleh dipakai di <a href>.
+var allowedSchemes = map[string]bool{"http": true, "https": true, "tg": true, "mailto": true}
+
+var (
+	tagPattern  = regexp.MustCompile(`^(/?)([a-zA-Z][a-zA-Z0-9-]*)(\s[^<>]*)?/?$`)
+	hrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startTag
+	endTag
+)
+
+// token adalah potongan HTML: teks mentah (entity belum dibuka) atau tag dengan atributnya.
+type token struct {
+	kind  tokenKind
+	name  string
+	attrs string
+	text  string
+}
+
+// tokenize mengurai HTML secara longgar: '<' yang tidak membentuk tag valid dianggap teks.
+func tokenize(s string) []token {
+	var tokens []token
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, token{kind: textToken, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] == '<' {
+			if end := strings.IndexByte(s[i+1:], '>'); end >= 0 {
+				// Seperti HTML, nama tag harus langsung setelah '<' ("a < b" tetap teks)
+				inner := strings.TrimRight(s[i+1:i+1+end], " \t\n")
+				if m := tagPattern.FindStringSubmatch(inner); m != nil {
+					flushText()
+					kind := startTag
+					if m[1] == "/" {
+						kind = endTag
+					}
+					tokens = append(tokens, token{kind: kind, name: strings.ToLower(m[2]), attrs: m[3]})
+					i += end + 2
+					continue
+				}
+			}
+		}
+		text.WriteByte(s[i])
+		i++
+	}
+	flushText()
+	return tokens
+}
+
+// escapeText meng-escape karakter yang wajib di-escape di teks HTML Telegram.
+func escapeText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// safeHref mengambil href dari atribut tag <a> dan hanya menerimanya jika skemanya aman.
+func safeHref(attrs string) (string, bool) {
+	m := hrefPattern.FindStringSubmatch(attrs)
+	if m == nil {
+		return "", false
+	}
+	href := html.UnescapeString(m[1] + m[2] + m[3])
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// openTag adalah tag yang sedang terbuka beserta teks pembukanya (untuk dibuka ulang).
+type openTag struct {
+	name string
+	open string
+}
+
+func (t openTag) close() string {
+	return "</" + t.name + ">"
+}
+
+// blockText mengganti tag baris dan blok yang tidak didukung Telegram dengan teks pemisah.
+func blockText(tok token) (string, bool) {
+	switch {
+	case tok.kind == startTag && tok.name == "br":
+		return "\n", true
+	case tok.kind == startTag && tok.name == "li":
+		return "\n• ", true
+	case tok.kind == endTag && (tok.name == "p" || tok.name == "div" || tok.name == "ul" || tok.name == "ol"):
+		return "\n", true
+	}
+	return "", false
+}
+
+// Sanitize mengubah balasan AI menjadi HTML yang diterima Telegram: tag di luar whitelist
+// dibuang (isinya dipertahankan), <br> dan paragraf menjadi baris baru, link dengan skema
+// tidak aman dibuang, tag di dalam <code>/<pre> diabaikan, dan semua tag diseimbangkan.
+func Sanitize(s string) string {
+	var out strings.Builder
+	var stack []openTag
+
+	inside := func(name string) bool {
+		for _, t := range stack {
+			if t.name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, tok := range tokenize(s) {
+		switch tok.kind {
+		case textToken:
+			out.WriteString(escapeText(html.UnescapeString(tok.text)))
+
+		case startTag:
+			if sep, ok := blockText(tok); ok {
+				out.WriteString(sep)
+				continue
+			}
+			name, ok := allowedTags[tok.name]
+			// Telegram tidak mengizinkan format di dalam <code>, dan di dalam <pre> hanya <code>
+			if !ok || inside("code") || (inside("pre") && name != "code") || (name == "a" && inside("a")) {
+				continue
+			}
+			open := "<" + name + ">"
+			if name == "a" {
+				href, ok := safeHref(tok.attrs)
+				if !ok {
+					continue
+				}
+				open = `<a href="` + escapeAttr(href) + `">`
+			}
+			out.WriteString(open)
+			stack = append(stack, openTag{name: name, open: open})
+
+		case endTag:
+			if sep, ok := blockText(tok); ok {
+				out.WriteString(sep)
+				continue
+			}
+			name, ok := allowedTags[tok.name]
+			if !ok {
+				continue
+			}
+			idx := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == name {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				// Tag penutup tanpa pembuka dibuang
+				continue
+			}
+			for i := len(stack) - 1; i >= idx; i-- {
+				out.WriteString(stack[i].close())
+			}
+			stack = stack[:idx]
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		out.WriteString(stack[i].close())
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// PlainText membuang semua tag dan membuka entity, untuk dikirim tanpa parse mode.
+func PlainText(s string) string {
+	var out strings.Builder
+	for _, tok := range tokenize(s) {
+		if tok.kind == textToken {
+			out.WriteString(html.UnescapeString(tok.text))
+		} else if sep, ok := blockText(tok); ok {
+			out.WriteString(sep)
+		}
+	}
+	return strings.TrimSpace(out.String())
+}
@@ -0,0 +1,151 @@
+package format
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// textLen menghitung panjang teks seperti Telegram: dalam unit UTF-16, jadi emoji dan
+// karakter di luar BMP dihitung dua.
+func textLen(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// breakPoint mencari posisi potong (byte) dalam s sehingga s[:cut] muat dalam room. Batas
+// paragraf, baris, kalimat lalu spasi diutamakan; tanpa batas yang cocok teks dipotong paksa.
+func breakPoint(s string, room int) int {
+	fit, used := 0, 0
+	for i, r := range s {
+		w := 1
+		if r >= 0x10000 {
+			w = 2
+		}
+		if used+w > room {
+			break
+		}
+		used += w
+		fit = i + utf8.RuneLen(r)
+	}
+	if fit >= len(s) {
+		return len(s)
+	}
+	head := s[:fit]
+	// Batas yang terlalu dekat ke awal menghasilkan pesan yang sangat pendek
+	min := fit / 2
+	for _, sep := range []string{"\n\n", "\n", ". ", "! ", "? ", " "} {
+		if idx := strings.LastIndex(head, sep); idx >= 0 && idx+len(sep) > min {
+			return idx + len(sep)
+		}
+	}
+	return fit
+}
+
+// SplitPlain memecah teks biasa menjadi potongan yang masing-masing muat dalam limit.
+func SplitPlain(s string, limit int) []string {
+	var parts []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		cut := breakPoint(s, limit)
+		if cut == 0 {
+			// limit lebih kecil dari satu karakter; hindari loop tanpa akhir
+			_, cut = utf8.DecodeRuneInString(s)
+		}
+		if part := strings.TrimSpace(s[:cut]); part != "" {
+			parts = append(parts, part)
+		}
+		s = strings.TrimSpace(s[cut:])
+	}
+	return parts
+}
+
+// Split memecah HTML hasil Sanitize menjadi beberapa pesan yang masing-masing muat dalam
+// limit karakter terlihat. Tag yang masih terbuka di titik potong ditutup di akhir pesan dan
+// dibuka lagi di awal pesan berikutnya, jadi setiap pesan tetap seimbang. Teks tanpa isi
+// terlihat menghasilkan nil.
+func Split(s string, limit int) []string {
+	var parts []string
+	var cur strings.Builder
+	var stack []openTag
+	curLen := 0
+
+	// Potongan tanpa teks terlihat (mis. "<b> </b>") ditolak Telegram, jadi tidak dikirim
+	appendPart := func() {
+		if part := strings.TrimSpace(cur.String()); curLen > 0 && PlainText(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	flush := func() {
+		for i := len(stack) - 1; i >= 0; i-- {
+			cur.WriteString(stack[i].close())
+		}
+		appendPart()
+		cur.Reset()
+		curLen = 0
+		for _, t := range stack {
+			cur.WriteString(t.open)
+		}
+	}
+
+	for _, tok := range tokenize(s) {
+		switch tok.kind {
+		case startTag:
+			if tok.name == "a" {
+				href, _ := safeHref(tok.attrs)
+				open := `<a href="` + escapeAttr(href) + `">`
+				cur.WriteString(open)
+				stack = append(stack, openTag{name: "a", open: open})
+				continue
+			}
+			open := "<" + tok.name + ">"
+			cur.WriteString(open)
+			stack = append(stack, openTag{name: tok.name, open: open})
+
+		case endTag:
+			cur.WriteString("</" + tok.name + ">")
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+
+		case textToken:
+			text := html.UnescapeString(tok.text)
+			for text != "" {
+				room := limit - curLen
+				if textLen(text) <= room {
+					cur.WriteString(escapeText(text))
+					curLen += textLen(text)
+					break
+				}
+				cut := 0
+				if room > 0 {
+					cut = breakPoint(text, room)
+				}
+				if cut == 0 {
+					if curLen == 0 {
+						// limit lebih kecil dari satu karakter; paksa satu karakter
+						_, cut = utf8.DecodeRuneInString(text)
+					} else {
+						flush()
+						continue
+					}
+				}
+				// Spasi di titik potong dibuang, juga jika masih di dalam tag
+				head := strings.TrimRight(text[:cut], " \n")
+				cur.WriteString(escapeText(head))
+				curLen += textLen(head)
+				flush()
+				text = strings.TrimLeft(text[cut:], " \n")
+			}
+		}
+	}
+	appendPart()
+	return parts
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 	"strings"
 	"tg-business-bot/internal/api"
 	"tg-business-bot/internal/database"
 	"tg-business-bot/internal/encryption"
+	"tg-business-bot/internal/format"
 	"tg-business-bot/internal/i18n"
 	"tg-business-bot/internal/models"
 )
@@ -16,15 +21,91 @@ CRITICAL RULE: You MUST use Telegram-compatible HTML for formatting.
 Supported tags: <b>bold</b>, <i>italic</i>, <u>underline</u>, <s>strikethrough</s>, <code>code</code>, <pre>preformatted</pre>, <a href="URL">link</a>.
 Do NOT use Markdown. Ensure all tags are properly closed. For new lines, just use a normal line break.`
 
+// Telegram adalah bagian Bot API yang dipakai handler. *api.TelegramClient memenuhinya;
+// test memakai implementasi palsu.
+type Telegram interface {
+	SendMessage(chatID int64, text string, businessConnID string, markup interface{}) (int64, error)
+	EditMessage(chatID int64, messageID int64, text string, markup interface{}) error
+	DeleteMessage(chatID int64, messageID int64) error
+	AnswerCallback(callbackQueryID string) error
+	SendLocation(chatID int64, lat, lon float64, businessConnID string) error
+	SendChatAction(chatID int64, action string, businessConnID string) error
+	SendText(chatID int64, text, parseMode, businessConnID string) (int64, error)
+	EditText(chatID, messageID int64, text, parseMode, businessConnID string) error
+	DeleteBusinessMessages(businessConnID string, messageIDs ...int64) error
+	GetFile(fileID string) (*api.File, error)
+	DownloadFile(filePath string, maxBytes int64) ([]byte, error)
+}
+
 type BotHandler struct {
-	DB         *database.SupabaseClient
-	TG         *api.TelegramClient
+	DB         database.Store
+	TG         Telegram
 	I18n       *i18n.Bundle
-	EncryptKey string
+	Keys       *encryption.Keyring
+
+	// LocalAIURL adalah alamat server Ollama milik operator; kosong berarti provider lokal tidak tersedia.
+	LocalAIURL string
+
+	// SharedKey adalah key Groq platform untuk owner tanpa key sendiri (kosong = nonaktif),
+	// dibatasi SharedDailyQuota balasan per owner per hari (0 = tanpa batas).
+	SharedKey        string
+	SharedDailyQuota int
+
+	// quotaMu hanya menjaga map di bawah ini (tidak pernah ditahan selama query database);
+	// quotaLocks mengurutkan pemesanan kuota per owner
+	quotaMu        sync.Mutex
+	quotaLocks     map[int64]*sync.Mutex
+	sharedInFlight map[int64]int
+	quotaNotice    map[int64]string
+	capNotice      map[int64]string
+
+	// HistoryMaxTokens membatasi anggaran konteks per balasan (0 = seluruh context window
+	// model); ReplyReserveTokens adalah ruang yang disisakan untuk balasan AI.
+	HistoryMaxTokens   int
+	ReplyReserveTokens int
+
+	// Ringkasan percakapan diperbarui jika pesan yang belum diringkas melewati SummaryThreshold
+	// (0 = nonaktif); SummaryKeepRecent pesan terbaru tidak pernah diringkas.
+	SummaryThreshold  int
+	SummaryKeepRecent int
+	summaries         *summarizer
+	// background menghitung goroutine latar belakang (pembaruan ringkasan) yang ditunggu Wait
+	background        sync.WaitGroup
+
+	// StreamEditInterval adalah jeda antar-edit pesan saat balasan di-stream (0 = tanpa streaming).
+	StreamEditInterval time.Duration
+
+	// awaySent mencatat sampai kapan pesan di luar jam buka tidak dikirim ulang per pelanggan.
+	awayMu   sync.Mutex
+	awaySent map[[2]int64]time.Time
+
+	// KnowledgeMaxTokens membatasi potongan knowledge base yang disisipkan per balasan.
+	KnowledgeMaxTokens int
+	knowledge          *knowledgeCache
+
+	// TakeoverCooldown adalah lama AI dijeda untuk pelanggan setelah owner membalas sendiri
+	// (0 = pesan owner hanya disimpan, AI tidak dijeda).
+	TakeoverCooldown time.Duration
+
+	// Prices adalah harga per model dari MODEL_PRICING untuk menghitung biaya pemakaian.
+	Prices map[string]ModelPrice
+
+	noticeMu        sync.Mutex
+	lastErrorNotice map[int64]time.Time
+
+	catalog *modelCatalog
 }
 
-func NewBotHandler(db *database.SupabaseClient, tg *api.TelegramClient, i18n *i18n.Bundle, encKey string) *BotHandler {
-	return &BotHandler{DB: db, TG: tg, I18n: i18n, EncryptKey: encKey}
+func NewBotHandler(db database.Store, tg Telegram, i18n *i18n.Bundle, keys *encryption.Keyring) *BotHandler {
+	return &BotHandler{DB: db, TG: tg, I18n: i18n, Keys: keys, lastErrorNotice: make(map[int64]time.Time), catalog: newModelCatalog(),
+		sharedInFlight: make(map[int64]int), quotaLocks: make(map[int64]*sync.Mutex), quotaNotice: make(map[int64]string), capNotice: make(map[int64]string), summaries: newSummarizer(),
+		awaySent: make(map[[2]int64]time.Time), knowledge: newKnowledgeCache()}
+}
+
+// Wait menunggu pekerjaan latar belakang yang dimulai handler selesai. Dipanggil saat shutdown
+// setelah dispatcher berhenti, supaya tidak ada ringkasan baru yang dimulai.
+func (h *BotHandler) Wait() {
+	h.background.Wait()
 }
 
 func (h *BotHandler) HandleUpdate(update api.Update) {
@@ -32,6 +113,10 @@ func (h *BotHandler) HandleUpdate(update api.Update) {
 		h.handleCallbackQuery(update.CallbackQuery)
 		return
 	}
+	if update.BusinessConnection != nil {
+		h.handleBusinessConnection(update.BusinessConnection)
+		return
+	}
 	if update.BusinessMessage != nil {
 		h.handleBusinessMessage(update.BusinessMessage)
 		return
@@ -49,30 +134,49 @@ func (h *BotHandler) refreshDashboard(chatID int64, user *models.User, status st
 	markup := h.getDashboardMarkup(user)
 
 	if user.LastDashboardID != 0 {
-		h.TG.EditMessage(chatID, user.LastDashboardID, text, markup)
-	} else {
-		// Fallback jika ID hilang, kirim pesan baru
-		msgID, _ := h.TG.SendMessage(chatID, text, "", markup)
-		user.LastDashboardID = msgID
-		h.DB.UpsertUser(*user)
+		err := h.TG.EditMessage(chatID, user.LastDashboardID, text, markup)
+		if err == nil {
+			return
+		}
+		// Pesan dashboard lama sudah dihapus atau terlalu lama untuk diedit
+		log.Printf("Warning: Failed to edit dashboard of user %d, sending a new one: %v", user.TelegramID, err)
+	}
+	// Fallback jika ID hilang atau edit gagal, kirim pesan baru
+	msgID, err := h.TG.SendMessage(chatID, text, "", markup)
+	if err != nil {
+		log.Printf("Warning: Failed to send dashboard to user %d: %v", user.TelegramID, err)
+		return
 	}
+	user.LastDashboardID = msgID
+	h.saveUser(user)
 }
 
 func (h *BotHandler) handlePrivateMessage(msg *api.Message) {
-    user, _ := h.DB.GetUser(msg.From.ID)
-    if user == nil {
-        h.DB.UpsertUser(models.User{TelegramID: msg.From.ID, Language: "en", AIModel: "openai/gpt-oss-120b", SystemPrompt: "You are a professional assistant.", IsPremium: msg.From.IsPremium})
-        user, _ = h.DB.GetUser(msg.From.ID)
+    user, err := h.loadUser(msg.From.ID, msg.From.IsPremium)
+    if err != nil {
+        log.Printf("Storage Error: private message from %d: %v", msg.From.ID, err)
+        h.TG.SendMessage(msg.Chat.ID, h.storageErrorText("en", err), "", nil)
+        return
     }
 
     if !msg.From.IsPremium {
         h.TG.SendMessage(msg.Chat.ID, h.I18n.Get("en", "access_denied"), "", nil)
         return
     }
+    // Profil owner dipakai untuk {{owner_name}} dan {{owner_username}}
+    if syncProfile(user, msg.From) {
+        h.saveUser(user)
+    }
 
     lang := user.Language
 
-    // 1. Handle Commands
+    // 1. Handle Commands (perintah selalu membatalkan input yang sedang ditunggu)
+    if msg.Text == "/start" || msg.Text == "/settings" {
+        if state, _ := h.getDialog(user.TelegramID); state != nil {
+            h.cancelDialog(user, state)
+        }
+    }
+
     if msg.Text == "/start" {
         markup := map[string]interface{}{
             "inline_keyboard": [][]map[string]interface{}{
@@ -90,55 +194,65 @@ func (h *BotHandler) handlePrivateMessage(msg *api.Message) {
         }
         msgID, _ := h.TG.SendMessage(msg.Chat.ID, h.getDashboardText(user, ""), "", h.getDashboardMarkup(user))
         user.LastDashboardID = msgID
-        h.DB.UpsertUser(*user)
+        h.saveUser(user)
         return
     }
 
-    // 2. Handle State Inputs (Logic Input User)
-    
-    // Logic input Lokasi
-    if strings.HasPrefix(user.BusinessLocation, "WAIT_FOR_LOCATION:") {
-        h.TG.DeleteMessage(msg.Chat.ID, msg.MessageID)
-        if msg.Location != nil {
-            user.Latitude = msg.Location.Latitude
-            user.Longitude = msg.Location.Longitude
-            user.BusinessLocation = fmt.Sprintf("https://www.google.com/maps?q=%f,%f", msg.Location.Latitude, msg.Location.Longitude)
-            h.DB.UpsertUser(*user)
-            h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(user.Language, "location_success"))
-        }
+    if msg.Text == "/preview" {
+        h.showPromptPreview(msg.Chat.ID, user)
         return
     }
 
-    // Logic input System Prompt - SEKARANG BERDIRI SENDIRI
-    if strings.HasPrefix(user.SystemPrompt, "WAIT_FOR_PROMPT:") {
-        user.SystemPrompt = msg.Text
-        h.DB.UpsertUser(*user)
-        h.TG.DeleteMessage(msg.Chat.ID, msg.MessageID)
-        h.refreshDashboard(msg.Chat.ID, user, "✅ <b>Prompt Updated!</b>")
+    // Dokumen yang dikirim owner masuk ke knowledge base
+    if msg.Document != nil {
+        h.handleKnowledgeUpload(msg, user)
         return
     }
 
-    // Logic input Groq Key - SEKARANG BERDIRI SENDIRI
-    if strings.HasPrefix(user.EncryptedGroqKey, "WAIT_FOR_KEY:") {
-        h.TG.DeleteMessage(msg.Chat.ID, msg.MessageID)
-        if !strings.HasPrefix(msg.Text, "gsk_") {
-            user.EncryptedGroqKey = "" // Reset state
-            h.DB.UpsertUser(*user)
-            h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(lang, "key_invalid"))
-            return
-        }
-        enc, _ := encryption.Encrypt(msg.Text, h.EncryptKey)
-        user.EncryptedGroqKey = enc
-        h.DB.UpsertUser(*user)
-        h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(lang, "key_success"))
+    // 2. Handle State Inputs (Logic Input User)
+    state, err := h.getDialog(user.TelegramID)
+    if err != nil {
+        log.Printf("Storage Error: %v", err)
         return
     }
+    if state != nil {
+        h.handleDialogInput(msg, user, state)
+    }
 }
 
 func (h *BotHandler) handleBusinessMessage(msg *api.Message) {
-	owner, _ := h.DB.GetUserByBusinessConnID(msg.BusinessConnectionID)
-	// Guard clause: pastikan owner valid dan tidak sedang input key/prompt
-	if owner == nil || owner.EncryptedGroqKey == "" || strings.HasPrefix(owner.EncryptedGroqKey, "WAIT_") {
+	var owner *models.User
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		owner, err = h.DB.GetUserByBusinessConnID(msg.BusinessConnectionID)
+		return err
+	})
+	if err != nil {
+		// Tanpa owner tidak ada yang bisa diberi tahu; lewati balasan
+		if !errors.Is(err, database.ErrNotFound) {
+			log.Printf("Storage Error: business connection %s: %v", msg.BusinessConnectionID, err)
+		}
+		return
+	}
+	if migrateLegacyWait(owner) {
+		h.saveUser(owner)
+	}
+	// Balasan bot sendiri (termasuk balasan AI ini) ikut dikirim balik sebagai business_message
+	// atas nama owner; sudah tersimpan sebagai giliran assistant, jadi abaikan
+	if msg.SenderBusinessBot != nil {
+		return
+	}
+	// Owner membalas sendiri: simpan sebagai giliran human agent, jangan dijawab AI
+	if isOwnerMessage(owner, msg) {
+		h.handleOwnerMessage(owner, msg)
+		return
+	}
+	// Guard clause: pastikan owner sudah mengatur key provider atau bisa memakai key platform
+	if !h.hasUsableKey(owner) {
+		return
+	}
+	// Koneksi dinonaktifkan atau bot tidak diberi hak membalas
+	if !owner.BusinessConnEnabled || !owner.BusinessCanReply {
 		return
 	}
 
@@ -156,25 +270,112 @@ func (h *BotHandler) handleBusinessMessage(msg *api.Message) {
 		}
 	}
 
-	// Simpan pesan user ke history
-	h.DB.SaveMessage(owner.TelegramID, msg.Chat.ID, displayName, "user", msg.Text)
-	history := h.DB.GetChatHistory(owner.TelegramID, msg.Chat.ID)
+	// Simpan pesan user ke history. Tanpa history yang lengkap AI akan menjawab tanpa konteks,
+	// jadi balasan dilewati dan owner diberi tahu jika database gagal.
+	err = database.Retry(dbRetryAttempts, func() error {
+		return h.DB.SaveMessage(owner.TelegramID, msg.Chat.ID, displayName, "user", msg.Text)
+	})
+	if err != nil {
+		h.notifyOwnerStorageError(owner, err)
+		return
+	}
+	// Owner sedang menangani pelanggan ini sendiri; pesan tetap tersimpan sebagai konteks
+	pausedUntil, err := h.chatPausedUntil(owner.TelegramID, msg.Chat.ID)
+	if err != nil {
+		h.notifyOwnerStorageError(owner, err)
+		return
+	}
+	if !pausedUntil.IsZero() {
+		return
+	}
+	// Di luar jam buka: pakai prompt khusus, kirim pesan tetap, atau diam
+	prompt, handled := h.awayAction(owner, msg, displayName)
+	if handled {
+		return
+	}
+	// Pesan lama diwakili ringkasan; yang diambil hanya pesan yang belum diringkas
+	history, summary, err := h.recentHistory(owner.TelegramID, msg.Chat.ID)
+	if err != nil {
+		h.notifyOwnerStorageError(owner, err)
+		return
+	}
 	
+	// Batas belanja owner menjeda balasan otomatis; pesan pelanggan tetap tersimpan di history
+	reached, err := h.reachedSpendCap(owner)
+	if err != nil {
+		h.notifyOwnerStorageError(owner, err)
+		return
+	}
+	if reached != "" {
+		h.notifySpendCap(owner, reached)
+		return
+	}
+
+	// Key platform dibatasi kuota harian
+	shared := h.usesSharedKey(owner)
+	if shared {
+		ok, err := h.reserveSharedQuota(owner.TelegramID)
+		if err != nil {
+			h.notifyOwnerStorageError(owner, err)
+			return
+		}
+		if !ok {
+			h.notifyQuotaExhausted(owner)
+			return
+		}
+		defer h.releaseSharedQuota(owner.TelegramID)
+	}
+
 	// Olah placeholder (termasuk lokasi bisnis)
-	dynamicPrompt := h.processPlaceholders(owner.SystemPrompt, owner, msg)
+	dynamicPrompt := h.processPlaceholders(prompt, owner, msg)
 
 	var final []models.ChatMessage
 	combinedPrompt := MasterHTMLPrompt + "\n\nBusiness Context: " + dynamicPrompt
+	if summary.Summary != "" {
+		combinedPrompt += "\n\nSummary of earlier conversation with this customer: " + summary.Summary
+	}
+	window := h.contextWindowFor(owner)
+	// Potongan dokumen owner yang relevan dengan pesan terakhir pelanggan
+	combinedPrompt += h.knowledgeContext(owner, history, window)
 	final = append(final, models.ChatMessage{Role: "system", Content: combinedPrompt})
-	final = append(final, history...)
-
-	key, _ := encryption.Decrypt(owner.EncryptedGroqKey, h.EncryptKey)
-	groq := api.NewGroqClient(key)
-	resp, _ := groq.GetChatCompletion(owner.AIModel, final)
+	// Isi context window model dengan pesan terbaru, sisakan ruang untuk balasan
+	final = append(final, fitHistory(combinedPrompt, history, window, h.ReplyReserveTokens)...)
 
-	// 1. Simpan dan kirim balasan teks dari AI
-	h.DB.SaveMessage(owner.TelegramID, msg.Chat.ID, displayName, "assistant", resp)
-	h.TG.SendMessage(msg.Chat.ID, resp, msg.BusinessConnectionID, nil)
+	provider, err := h.newChatProvider(owner)
+	if err != nil {
+		log.Printf("AI Provider Error: owner %d: %v", owner.TelegramID, err)
+		return
+	}
+	// Tampilkan "typing" dan (jika didukung) balasan bertahap selama model bekerja
+	completion, previewID, err := h.generateReply(provider, owner.AIModel, final, msg)
+	if err != nil {
+		log.Printf("AI Provider Error: owner %d: %v", owner.TelegramID, err)
+		if previewID != 0 {
+			log.Printf("Warning: stream for chat %d broke off, discarding the partial reply", msg.Chat.ID)
+			h.discardPreview(owner, msg, previewID)
+		}
+		if errors.Is(err, api.ErrUnauthorized) || errors.Is(err, api.ErrForbidden) {
+			if shared {
+				log.Printf("Critical Error: Shared GROQ_API_KEY was rejected by the provider: %v", err)
+			} else {
+				h.updateKeyStatus(owner, keyStatusFromError(err, owner.KeyStatus != keyStatusInvalid))
+			}
+		}
+		return
+	}
+	h.recordUsage(owner, msg.Chat.ID, shared, completion.Usage)
+	// Bersihkan HTML sesuai whitelist; versi ini juga yang disimpan di riwayat
+	resp := format.Sanitize(completion.Content)
+
+	// 1. Simpan dan kirim balasan teks dari AI. Balasan tetap dikirim walau gagal disimpan.
+	err = database.Retry(dbRetryAttempts, func() error {
+		return h.DB.SaveMessage(owner.TelegramID, msg.Chat.ID, displayName, "assistant", resp)
+	})
+	if err != nil {
+		h.notifyOwnerStorageError(owner, err)
+	}
+	h.deliverReply(owner, msg, resp, previewID)
+	h.refreshSummaryAsync(owner, msg.Chat.ID)
 
 	// 2. Logika Kirim Sharelok Otomatis
 	if owner.Latitude != 0 && owner.Longitude != 0 {
@@ -184,91 +385,120 @@ func (h *BotHandler) handleBusinessMessage(msg *api.Message) {
 
 		if isMentioningLoc {
 			// Kirim pin lokasi asli Telegram
-			h.TG.SendLocation(msg.Chat.ID, owner.Latitude, owner.Longitude, msg.BusinessConnectionID)
+			if err := h.TG.SendLocation(msg.Chat.ID, owner.Latitude, owner.Longitude, msg.BusinessConnectionID); err != nil {
+				log.Printf("Warning: Failed to send location to chat %d: %v", msg.Chat.ID, err)
+			}
 		}
 	}
 }
 
 func (h *BotHandler) handleCallbackQuery(cb *api.CallbackQuery) {
     h.TG.AnswerCallback(cb.ID)
-    user, _ := h.DB.GetUser(cb.From.ID)
-    if user == nil {
+    user, err := h.loadUser(cb.From.ID, cb.From.IsPremium)
+    if err != nil {
+        log.Printf("Storage Error: callback from %d: %v", cb.From.ID, err)
+        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.storageErrorText("en", err), nil)
         return
     }
 
     // Selalu sinkronkan ID pesan dashboard terbaru
     user.LastDashboardID = cb.Msg.MessageID
-    h.DB.UpsertUser(*user)
+    h.saveUser(user)
+
+    // Menekan tombol apa pun meninggalkan input yang sedang ditunggu
+    if state, err := h.getDialog(user.TelegramID); err == nil && state != nil {
+        h.cancelDialog(user, state)
+    }
 
     lang := user.Language
 
-    if cb.Data == "menu_model" {
-        markup := map[string]interface{}{
-            "inline_keyboard": [][]map[string]interface{}{
-                {{"text": "GPT-OSS 120B", "callback_data": "set_model_openai/gpt-oss-120b"}},
-                {{"text": "Llama 4 Maverick", "callback_data": "set_model_meta-llama/llama-4-maverick-17b-128e-instruct"}},
-                {{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}},
-            },
+    if cb.Data == "menu_model" || strings.HasPrefix(cb.Data, "model_page_") {
+        var page int
+        fmt.Sscanf(strings.TrimPrefix(cb.Data, "model_page_"), "%d", &page)
+        h.showModelMenu(cb.From.ID, cb.Msg.MessageID, user, page)
+
+    } else if cb.Data == "menu_provider" {
+        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "select_provider"), h.getProviderMarkup(lang))
+
+    } else if strings.HasPrefix(cb.Data, "set_provider_") {
+        provider := strings.TrimPrefix(cb.Data, "set_provider_")
+        if _, ok := defaultModels[provider]; !ok || (provider == api.ProviderLocal && h.LocalAIURL == "") {
+            h.refreshDashboard(cb.From.ID, user, "")
+            return
+        }
+        backup, _ := json.Marshal(providerBackup{Provider: user.AIProvider, Model: user.AIModel, EncryptedKey: user.EncryptedGroqKey, KeyStatus: user.KeyStatus, BaseURL: user.AIBaseURL})
+        if provider != providerOf(user) {
+            // Key dan model provider lama tidak berlaku di provider baru
+            user.AIProvider = provider
+            user.AIModel = defaultModels[provider]
+            user.EncryptedGroqKey = ""
+            user.KeyStatus = ""
+            user.AIBaseURL = ""
         }
-        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "select_model"), markup)
+        h.saveUser(user)
+        if provider == api.ProviderOpenAI {
+            // Minta base URL endpoint milik owner; pengaturan lama dipulihkan jika dibatalkan
+            h.startDialog(user, stepAwaitBaseURL, inputURL, string(backup), cb.Msg.MessageID)
+            return
+        }
+        h.refreshDashboard(cb.From.ID, user, "")
 
     } else if strings.HasPrefix(cb.Data, "set_model_") {
-        user.AIModel = strings.TrimPrefix(cb.Data, "set_model_")
-        h.DB.UpsertUser(*user)
+        model, err := h.resolveModelChoice(user, strings.TrimPrefix(cb.Data, "set_model_"))
+        if err != nil {
+            h.refreshDashboard(cb.From.ID, user, h.I18n.Get(lang, "model_list_failed"))
+            return
+        }
+        if model == "" {
+            // Model sudah dipensiunkan provider atau tidak ada di katalog
+            h.refreshDashboard(cb.From.ID, user, h.I18n.Get(lang, "model_unavailable"))
+            return
+        }
+        user.AIModel = model
+        h.saveUser(user)
         h.refreshDashboard(cb.From.ID, user, "")
 
     } else if cb.Data == "menu_prompt" {
-        // Backup prompt lama jika belum dalam mode WAIT
-        if !strings.HasPrefix(user.SystemPrompt, "WAIT_FOR_PROMPT:") {
-            user.SystemPrompt = "WAIT_FOR_PROMPT:" + user.SystemPrompt
-            h.DB.UpsertUser(*user)
-        }
-        markup := map[string]interface{}{
-            "inline_keyboard": [][]map[string]interface{}{
-                {{"text": h.I18n.Get(lang, "btn_cancel"), "callback_data": "back_main"}},
-            },
-        }
-        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "prompt_input"), markup)
+        h.startDialog(user, stepAwaitPrompt, inputText, "", cb.Msg.MessageID)
 
     } else if cb.Data == "menu_key" {
-        // PERBAIKAN: Backup key lama agar tidak hilang jika di-cancel
-        if !strings.HasPrefix(user.EncryptedGroqKey, "WAIT_FOR_KEY:") {
-            user.EncryptedGroqKey = "WAIT_FOR_KEY:" + user.EncryptedGroqKey
-            h.DB.UpsertUser(*user)
-        }
-        markup := map[string]interface{}{
-            "inline_keyboard": [][]map[string]interface{}{
-                {{"text": h.I18n.Get(lang, "btn_cancel"), "callback_data": "back_main"}},
-            },
-        }
-        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "key_input"), markup)
+        h.startDialog(user, stepAwaitKey, inputSecret, "", cb.Msg.MessageID)
+
+    } else if cb.Data == "menu_usage" {
+        h.showUsage(cb.From.ID, cb.Msg.MessageID, user)
+
+    } else if cb.Data == "menu_paused" {
+        h.showPausedChats(cb.From.ID, cb.Msg.MessageID, user, "")
+
+    } else if strings.HasPrefix(cb.Data, "resume_") {
+        h.resumeChat(cb.From.ID, cb.Msg.MessageID, user, cb.Data)
+
+    } else if cb.Data == "menu_hours" || strings.HasPrefix(cb.Data, "hours_") || strings.HasPrefix(cb.Data, "away_mode_") {
+        h.handleHoursCallback(cb, user)
+
+    } else if cb.Data == "menu_vars" {
+        h.showPromptVars(cb.From.ID, cb.Msg.MessageID, user, "")
+
+    } else if cb.Data == "vars_edit" {
+        h.startDialog(user, stepAwaitVars, inputText, "", cb.Msg.MessageID)
+
+    } else if cb.Data == "menu_preview" {
+        h.showPromptPreview(cb.From.ID, user)
+
+    } else if cb.Data == "menu_kb" {
+        h.showKnowledge(cb.From.ID, cb.Msg.MessageID, user, "")
+
+    } else if strings.HasPrefix(cb.Data, "kb_del_") {
+        h.deleteKnowledgeDocument(cb.From.ID, cb.Msg.MessageID, user, cb.Data)
+
+    } else if cb.Data == "menu_caps" {
+        h.startDialog(user, stepAwaitSpendCaps, inputText, "", cb.Msg.MessageID)
 
     } else if cb.Data == "menu_location" {
-        // Backup lokasi lama jika belum dalam mode WAIT
-        if !strings.HasPrefix(user.BusinessLocation, "WAIT_FOR_LOCATION:") {
-            user.BusinessLocation = "WAIT_FOR_LOCATION:" + user.BusinessLocation
-            h.DB.UpsertUser(*user)
-        }
-        markup := map[string]interface{}{
-            "inline_keyboard": [][]map[string]interface{}{
-                {{"text": h.I18n.Get(lang, "btn_cancel"), "callback_data": "back_main"}},
-            },
-        }
-        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "location_input"), markup)
+        h.startDialog(user, stepAwaitLocation, inputLocation, "", cb.Msg.MessageID)
 
     } else if cb.Data == "back_main" {
-        // LOGIKA CLEANUP: Mengembalikan semua data ke state asli (menghapus prefix WAIT)
-        if strings.HasPrefix(user.SystemPrompt, "WAIT_FOR_PROMPT:") {
-            user.SystemPrompt = strings.TrimPrefix(user.SystemPrompt, "WAIT_FOR_PROMPT:")
-        }
-        if strings.HasPrefix(user.EncryptedGroqKey, "WAIT_FOR_KEY:") {
-            user.EncryptedGroqKey = strings.TrimPrefix(user.EncryptedGroqKey, "WAIT_FOR_KEY:")
-        }
-        if strings.HasPrefix(user.BusinessLocation, "WAIT_FOR_LOCATION:") {
-            user.BusinessLocation = strings.TrimPrefix(user.BusinessLocation, "WAIT_FOR_LOCATION:")
-        }
-
-        h.DB.UpsertUser(*user)
+        // Dialog yang tertunda sudah dibatalkan di atas
         h.refreshDashboard(cb.From.ID, user, "")
 
     } else if cb.Data == "menu_lang" {
@@ -283,11 +513,23 @@ func (h *BotHandler) handleCallbackQuery(cb *api.CallbackQuery) {
 
     } else if strings.HasPrefix(cb.Data, "set_lang_") {
         user.Language = strings.TrimPrefix(cb.Data, "set_lang_")
-        h.DB.UpsertUser(*user)
+        h.saveUser(user)
         h.refreshDashboard(cb.From.ID, user, "")
 
     } else if cb.Data == "menu_clear_list" {
-        customers := h.DB.GetBusinessCustomers(user.TelegramID)
+        var customers []map[string]interface{}
+        err := database.Retry(dbRetryAttempts, func() error {
+            var err error
+            customers, err = h.DB.GetBusinessCustomers(user.TelegramID)
+            return err
+        })
+        if err != nil {
+            log.Printf("Storage Error: %v", err)
+            h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.storageErrorText(lang, err), map[string]interface{}{
+                "inline_keyboard": [][]map[string]interface{}{{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}}},
+            })
+            return
+        }
         var buttons [][]map[string]interface{}
         if len(customers) == 0 {
             h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "no_history"), map[string]interface{}{
@@ -322,8 +564,15 @@ func (h *BotHandler) handleCallbackQuery(cb *api.CallbackQuery) {
         cid := strings.TrimPrefix(cb.Data, "exec_clear_")
         var targetID int64
         fmt.Sscanf(cid, "%d", &targetID)
-        h.DB.ClearHistoryPerUser(user.TelegramID, targetID)
-        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, h.I18n.Get(lang, "history_cleared"), map[string]interface{}{
+        text := h.I18n.Get(lang, "history_cleared")
+        err := database.Retry(dbRetryAttempts, func() error {
+            return h.DB.ClearHistoryPerUser(user.TelegramID, targetID)
+        })
+        if err != nil {
+            log.Printf("Storage Error: %v", err)
+            text = h.storageErrorText(lang, err)
+        }
+        h.TG.EditMessage(cb.From.ID, cb.Msg.MessageID, text, map[string]interface{}{
             "inline_keyboard": [][]map[string]interface{}{{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "menu_clear_list"}}},
         })
     }
@@ -334,29 +583,34 @@ func (h *BotHandler) getDashboardText(user *models.User, status string) string {
 
 	// Tampilan Lokasi
 	locDisplay := user.BusinessLocation
-	if strings.HasPrefix(locDisplay, "WAIT_FOR_LOCATION:") {
-		locDisplay = h.I18n.Get(lang, "wait_input")
-	}
 	if locDisplay == "" { locDisplay = "<i>Not set</i>" }
 
 	// Tampilan Prompt
 	p := user.SystemPrompt
-	if strings.HasPrefix(p, "WAIT_FOR_PROMPT:") { p = h.I18n.Get(lang, "wait_input") }
 	p = strings.ReplaceAll(strings.ReplaceAll(p, "<", "&lt;"), ">", "&gt;")
 	
-	keyStatus := h.I18n.Get(lang, "key_not_set")
-	if user.EncryptedGroqKey != "" && !strings.HasPrefix(user.EncryptedGroqKey, "WAIT_") { keyStatus = h.I18n.Get(lang, "key_set") }
+	keyStatus := h.keyStatusText(user)
+
+	providerDisplay := providerOf(user)
+	if user.AIBaseURL != "" {
+		providerDisplay += " · " + user.AIBaseURL
+	}
 	
 	header := h.I18n.Get(lang, "dash_title")
 	if status != "" { header = status + "\n\n" + header }
 	
-	// Pastikan ada 9 parameter %s yang sesuai
-	return fmt.Sprintf("%s\n\n%s <code>%s</code>\n%s <code>%s</code>\n%s <code>%s</code>\n%s\n<pre>%s</pre>", 
+	tz := user.Timezone
+	if tz == "" { tz = "UTC" }
+
+	// Pastikan ada 13 parameter %s yang sesuai
+	return fmt.Sprintf("%s\n\n%s <code>%s</code>\n%s <code>%s</code>\n%s <code>%s</code>\n%s <code>%s</code>\n%s <code>%s</code>\n%s\n<pre>%s</pre>", 
 		header,                                  // 1
-		h.I18n.Get(lang, "dash_model"), user.AIModel, // 2, 3
-		h.I18n.Get(lang, "dash_key"), keyStatus,      // 4, 5
-		h.I18n.Get(lang, "dash_location"), locDisplay, // 6, 7
-		h.I18n.Get(lang, "dash_prompt"), p,            // 8, 9
+		h.I18n.Get(lang, "dash_provider"), providerDisplay, // 2, 3
+		h.I18n.Get(lang, "dash_model"), user.AIModel, // 4, 5
+		h.I18n.Get(lang, "dash_key"), keyStatus,      // 6, 7
+		h.I18n.Get(lang, "dash_location"), locDisplay, // 8, 9
+		h.I18n.Get(lang, "dash_timezone"), tz,          // 10, 11
+		h.I18n.Get(lang, "dash_prompt"), p,            // 12, 13
 	)
 }
 
@@ -365,53 +619,86 @@ func (h *BotHandler) getDashboardMarkup(user *models.User) map[string]interface{
 	return map[string]interface{}{
 		"inline_keyboard": [][]map[string]interface{}{
 			{
+				{"text": h.I18n.Get(lang, "btn_provider"), "callback_data": "menu_provider"},
 				{"text": h.I18n.Get(lang, "btn_model"), "callback_data": "menu_model"},
-				{"text": h.I18n.Get(lang, "btn_prompt"), "callback_data": "menu_prompt"},
 			},
 			{
+				{"text": h.I18n.Get(lang, "btn_prompt"), "callback_data": "menu_prompt"},
 				{"text": h.I18n.Get(lang, "btn_set_location"), "callback_data": "menu_location"},
 			},
+			{
+				{"text": h.I18n.Get(lang, "btn_vars"), "callback_data": "menu_vars"},
+				{"text": h.I18n.Get(lang, "btn_preview"), "callback_data": "menu_preview"},
+			},
+			{
+				{"text": h.I18n.Get(lang, "btn_knowledge"), "callback_data": "menu_kb"},
+			},
 			{
 				{"text": h.I18n.Get(lang, "btn_update_key"), "callback_data": "menu_key"},
 				{"text": h.I18n.Get(lang, "btn_clear_history"), "callback_data": "menu_clear_list"},
 			},
 			{
+				{"text": h.I18n.Get(lang, "btn_usage"), "callback_data": "menu_usage"},
+				{"text": h.I18n.Get(lang, "btn_paused"), "callback_data": "menu_paused"},
+			},
+			{
+				{"text": h.I18n.Get(lang, "btn_hours"), "callback_data": "menu_hours"},
 				{"text": "🌐 Language", "callback_data": "menu_lang"},
 			},
 		},
 	}
 }
 
+// handleBusinessConnection menyimpan status koneksi bisnis (aktif, tanggal, hak bot)
+// dan memberi tahu owner lewat chat pribadi setiap kali statusnya berubah.
 func (h *BotHandler) handleBusinessConnection(conn *api.BusinessConnection) {
-	user, _ := h.DB.GetUser(conn.UserChatID)
-	if user != nil {
-		user.BusinessConnID = conn.ID
-		h.DB.UpsertUser(*user)
+	ownerID := conn.UserChatID
+	if conn.User != nil {
+		ownerID = conn.User.ID
 	}
-}
 
-// --- NEW HELPER FUNCTION ---
-// processPlaceholders mengganti tag dinamis dalam prompt dengan data real-time.
-func (h *BotHandler) processPlaceholders(prompt string, owner *models.User, msg *api.Message) string {
-	now := time.Now()
-	
-	// Data Pelanggan
-	customerName := msg.From.FirstName
-	if msg.From.LastName != "" { customerName += " " + msg.From.LastName }
-	customerUsername := "@" + msg.From.Username
-	if msg.From.Username == "" { customerUsername = "n/a" }
-
-	// Mapping Placeholder
-	replacer := strings.NewReplacer(
-		"{{customer_name}}", customerName,
-		"{{customer_username}}", customerUsername,
-		"{{customer_id}}", fmt.Sprintf("%d", msg.From.ID),
-		"{{owner_name}}", "Owner", // Bisa diambil dari data owner jika tersedia
-		"{{current_time}}", now.Format("15:04"),
-		"{{current_date}}", now.Format("02 January 2006"),
-		"{{current_day}}", now.Weekday().String(),
-		"{{business_location}}", owner.BusinessLocation, // <-- TAMBAHKAN INI
-	)
+	isPremium := conn.User != nil && conn.User.IsPremium
+	user, err := h.loadUser(ownerID, isPremium)
+	if err != nil {
+		log.Printf("Storage Error: business connection %s: %v", conn.ID, err)
+		h.TG.SendMessage(conn.UserChatID, h.storageErrorText("en", err), "", nil)
+		return
+	}
 
-	return replacer.Replace(prompt)
-}
\ No newline at end of file
+	wasActive := user.BusinessConnID == conn.ID && user.BusinessConnEnabled
+	couldReply := user.BusinessCanReply
+
+	user.BusinessConnID = conn.ID
+	user.BusinessConnEnabled = conn.IsEnabled
+	user.BusinessConnDate = conn.Date
+	user.BusinessUserChatID = conn.UserChatID
+	syncProfile(user, conn.User)
+	user.BusinessCanReply = conn.CanBotReply()
+	if conn.Rights != nil {
+		user.BusinessCanReadMessages = conn.Rights.CanReadMessages
+		user.BusinessCanDeleteMessages = conn.Rights.CanDeleteSentMessages || conn.Rights.CanDeleteAllMessages
+	}
+	if err := h.saveUser(user); err != nil {
+		// Tanpa koneksi tersimpan, pesan bisnis tidak bisa dipetakan ke owner
+		h.TG.SendMessage(conn.UserChatID, h.storageErrorText(user.Language, err), "", nil)
+		return
+	}
+
+	lang := user.Language
+	var notice string
+	switch {
+	case !conn.IsEnabled:
+		notice = h.I18n.Get(lang, "conn_disabled")
+	case !wasActive:
+		notice = h.I18n.Get(lang, "conn_created")
+		if !user.BusinessCanReply {
+			notice += "\n\n" + h.I18n.Get(lang, "conn_no_reply_right")
+		}
+	case couldReply && !user.BusinessCanReply:
+		notice = h.I18n.Get(lang, "conn_revoked")
+	}
+
+	if notice != "" {
+		h.TG.SendMessage(conn.UserChatID, notice, "", nil)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/i18n"
+)
+
+type sentMessage struct {
+	chatID int64
+	text   string
+}
+
+// fakeTelegram mencatat pesan yang dikirim handler. Method yang tidak dipakai test
+// jatuh ke Telegram yang nil dan akan panic.
+type fakeTelegram struct {
+	Telegram
+	sent    []sentMessage
+	deleted []int64
+}
+
+func (f *fakeTelegram) SendMessage(chatID int64, text string, businessConnID string, markup interface{}) (int64, error) {
+	f.sent = append(f.sent, sentMessage{chatID, text})
+	return int64(100 + len(f.sent)), nil
+}
+
+func (f *fakeTelegram) SendText(chatID int64, text, parseMode, businessConnID string) (int64, error) {
+	return f.SendMessage(chatID, text, businessConnID, nil)
+}
+
+func (f *fakeTelegram) DeleteMessage(chatID int64, messageID int64) error {
+	f.deleted = append(f.deleted, messageID)
+	return nil
+}
+
+func newTestHandler(t *testing.T) (*BotHandler, *fakeTelegram) {
+	t.Helper()
+	bundle := i18n.NewBundle()
+	if err := bundle.LoadLocale("en", "../../locales/en.json"); err != nil {
+		t.Fatal(err)
+	}
+	tg := &fakeTelegram{}
+	return &BotHandler{DB: database.NewMemoryStore(), TG: tg, I18n: bundle}, tg
+}
+
+func privateMessage(userID int64, premium bool, text string) *api.Message {
+	return &api.Message{
+		MessageID: 1,
+		From:      &api.User{ID: userID, IsPremium: premium},
+		Chat:      &api.Chat{ID: userID},
+		Text:      text,
+	}
+}
+
+func TestPrivateMessageRequiresPremium(t *testing.T) {
+	h, tg := newTestHandler(t)
+
+	h.handlePrivateMessage(privateMessage(7, false, "/settings"))
+
+	if len(tg.sent) != 1 || tg.sent[0].text != h.I18n.Get("en", "access_denied") {
+		t.Fatalf("sent %+v, want only access_denied", tg.sent)
+	}
+}
+
+func TestSettingsReplacesDashboard(t *testing.T) {
+	h, tg := newTestHandler(t)
+
+	h.handlePrivateMessage(privateMessage(7, true, "/settings"))
+	user, err := h.DB.GetUser(7)
+	if err != nil || user == nil {
+		t.Fatalf("GetUser after /settings = %v, %v", user, err)
+	}
+	first := user.LastDashboardID
+	if first == 0 || len(tg.sent) != 1 {
+		t.Fatalf("dashboard not sent or not stored: sent %+v, LastDashboardID %d", tg.sent, first)
+	}
+
+	h.handlePrivateMessage(privateMessage(7, true, "/settings"))
+	user, _ = h.DB.GetUser(7)
+	if len(tg.deleted) != 1 || tg.deleted[0] != first {
+		t.Fatalf("deleted %v, want old dashboard %d", tg.deleted, first)
+	}
+	if user.LastDashboardID == first {
+		t.Fatalf("LastDashboardID still %d after a new dashboard", first)
+	}
+}
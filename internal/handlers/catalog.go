@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// modelCatalogTTL adalah lama katalog /models disimpan sebelum diambil ulang dari provider.
+const modelCatalogTTL = 10 * time.Minute
+
+// modelsPerPage adalah jumlah model per halaman keyboard menu model.
+const modelsPerPage = 8
+
+// modelCatalog menyimpan katalog model per kombinasi provider, endpoint dan key owner.
+type modelCatalog struct {
+	mu      sync.Mutex
+	entries map[string]catalogEntry
+}
+
+type catalogEntry struct {
+	models  []api.ModelInfo
+	fetched time.Time
+}
+
+func newModelCatalog() *modelCatalog {
+	return &modelCatalog{entries: make(map[string]catalogEntry)}
+}
+
+// catalogKey tidak memuat key mentah; ciphertext berubah setiap kali owner mengganti key
+// sehingga katalog lama otomatis tidak terpakai.
+func catalogKey(owner *models.User, baseURL string) string {
+	sum := sha256.Sum256([]byte(providerOf(owner) + "|" + baseURL + "|" + owner.EncryptedGroqKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// getModels mengembalikan katalog model owner dari cache, atau mengambilnya dari provider
+// jika sudah kedaluwarsa atau refresh diminta.
+func (h *BotHandler) getModels(owner *models.User, refresh bool) ([]api.ModelInfo, error) {
+	baseURL := owner.AIBaseURL
+	if providerOf(owner) == api.ProviderLocal {
+		baseURL = h.LocalAIURL
+	}
+	key := catalogKey(owner, baseURL)
+
+	h.catalog.mu.Lock()
+	entry, ok := h.catalog.entries[key]
+	h.catalog.mu.Unlock()
+	if ok && !refresh && time.Since(entry.fetched) < modelCatalogTTL {
+		return entry.models, nil
+	}
+
+	provider, err := h.newChatProvider(owner)
+	if err != nil {
+		return nil, err
+	}
+	list, err := provider.ListModels()
+	if err != nil {
+		return nil, err
+	}
+
+	h.catalog.mu.Lock()
+	h.catalog.entries[key] = catalogEntry{models: list, fetched: time.Now()}
+	h.catalog.mu.Unlock()
+	return list, nil
+}
+
+// resolveModelChoice memetakan callback menu model ke ID model yang masih aktif. choice berisi
+// ID model, atau "#<index>" jika ID terlalu panjang untuk callback_data (maks. 64 byte).
+// Katalog selalu diambil ulang sebelum menyimpan supaya model yang baru saja dipensiunkan
+// provider tidak bisa dipilih. Mengembalikan string kosong jika model tidak tersedia.
+func (h *BotHandler) resolveModelChoice(owner *models.User, choice string) (string, error) {
+	if strings.HasPrefix(choice, "#") {
+		// Index merujuk ke katalog yang sedang ditampilkan (cache)
+		list, err := h.getModels(owner, false)
+		if err != nil {
+			return "", err
+		}
+		idx, err := strconv.Atoi(strings.TrimPrefix(choice, "#"))
+		if err != nil || idx < 0 || idx >= len(list) {
+			return "", nil
+		}
+		choice = list[idx].ID
+	}
+
+	list, err := h.getModels(owner, true)
+	if err != nil {
+		return "", err
+	}
+	if hasModel(list, choice) {
+		return choice, nil
+	}
+	return "", nil
+}
+
+func hasModel(list []api.ModelInfo, id string) bool {
+	for _, m := range list {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// formatContextWindow menampilkan ukuran context window secara ringkas, misalnya 131072 -> "128K".
+func formatContextWindow(tokens int) string {
+	switch {
+	case tokens <= 0:
+		return ""
+	case tokens >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(tokens)/1048576)
+	}
+	return fmt.Sprintf("%dK", tokens/1024)
+}
+
+// showModelMenu menampilkan katalog model owner sebagai keyboard berhalaman.
+func (h *BotHandler) showModelMenu(chatID, messageID int64, user *models.User, page int) {
+	lang := user.Language
+	back := []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}}
+
+	if !h.hasUsableKey(user) {
+		h.TG.EditMessage(chatID, messageID, h.I18n.Get(lang, "model_need_key"), map[string]interface{}{
+			"inline_keyboard": [][]map[string]interface{}{back},
+		})
+		return
+	}
+
+	list, err := h.getModels(user, false)
+	if err != nil || len(list) == 0 {
+		h.TG.EditMessage(chatID, messageID, h.I18n.Get(lang, "model_list_failed"), map[string]interface{}{
+			"inline_keyboard": [][]map[string]interface{}{back},
+		})
+		return
+	}
+
+	pages := (len(list) + modelsPerPage - 1) / modelsPerPage
+	if page < 0 || page >= pages {
+		page = 0
+	}
+
+	var rows [][]map[string]interface{}
+	start := page * modelsPerPage
+	for i := start; i < start+modelsPerPage && i < len(list); i++ {
+		m := list[i]
+		label := m.ID
+		if ctx := formatContextWindow(m.ContextWindow); ctx != "" {
+			label += " · " + ctx
+		}
+		if m.ID == user.AIModel {
+			label = "✅ " + label
+		}
+		data := "set_model_" + m.ID
+		if len(data) > 64 {
+			data = fmt.Sprintf("set_model_#%d", i)
+		}
+		rows = append(rows, []map[string]interface{}{{"text": label, "callback_data": data}})
+	}
+
+	var nav []map[string]interface{}
+	if page > 0 {
+		nav = append(nav, map[string]interface{}{"text": "‹", "callback_data": fmt.Sprintf("model_page_%d", page-1)})
+	}
+	if page < pages-1 {
+		nav = append(nav, map[string]interface{}{"text": "›", "callback_data": fmt.Sprintf("model_page_%d", page+1)})
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, back)
+
+	text := fmt.Sprintf("%s\n<i>%d/%d</i>", h.I18n.Get(lang, "select_model"), page+1, pages)
+	h.TG.EditMessage(chatID, messageID, text, map[string]interface{}{"inline_keyboard": rows})
+}
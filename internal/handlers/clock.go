@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"tg-business-bot/internal/models"
+	"tg-business-bot/internal/schedule"
+	"time"
+)
+
+// commonTimezones ditampilkan sebagai pilihan cepat; zona lain bisa diketik manual.
+var commonTimezones = []string{
+	"Asia/Jakarta", "Asia/Makassar", "Asia/Jayapura", "Asia/Singapore",
+	"Europe/Moscow", "Asia/Yekaterinburg", "Europe/London", "America/New_York",
+	"UTC",
+}
+
+// ownerLocation mengembalikan zona waktu owner. Zona yang tidak bisa dimuat (mis. tzdata server
+// tidak lengkap) dicatat dan diganti UTC.
+func ownerLocation(owner *models.User) *time.Location {
+	loc, err := schedule.LoadLocation(owner.Timezone)
+	if err != nil {
+		log.Printf("Warning: invalid timezone of owner %d: %v", owner.TelegramID, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// localName mengambil nama ke-i dari daftar nama yang dipisah koma di locale, dengan nama
+// bahasa Inggris sebagai cadangan jika daftar tidak lengkap.
+func (h *BotHandler) localName(lang, key string, i int, fallback string) string {
+	names := strings.Split(h.I18n.Get(lang, key), ",")
+	if i < len(names) && strings.TrimSpace(names[i]) != "" {
+		return strings.TrimSpace(names[i])
+	}
+	return fallback
+}
+
+// localDay mengembalikan nama hari t dalam bahasa lang.
+func (h *BotHandler) localDay(lang string, t time.Time) string {
+	return h.localName(lang, "day_names", int(t.Weekday()), t.Weekday().String())
+}
+
+// localDate memformat tanggal t seperti "17 October 2026" dengan nama bulan dalam bahasa lang.
+func (h *BotHandler) localDate(lang string, t time.Time) string {
+	month := h.localName(lang, "month_names", int(t.Month())-1, t.Month().String())
+	return fmt.Sprintf("%02d %s %d", t.Day(), month, t.Year())
+}
+
+// localOpening memformat waktu buka seperti "Monday, 19 October 2026 09:00 WIB".
+func (h *BotHandler) localOpening(lang string, t time.Time) string {
+	return h.localDay(lang, t) + ", " + h.localDate(lang, t) + " " + t.Format("15:04 MST")
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/encryption"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// Langkah dialog yang menunggu input owner di chat pribadi.
+const (
+	stepAwaitPrompt    = "await_prompt"
+	stepAwaitKey       = "await_key"
+	stepAwaitLocation  = "await_location"
+	stepAwaitBaseURL   = "await_base_url"
+	stepAwaitSpendCaps = "await_spend_caps"
+	stepAwaitVars      = "await_vars"
+
+	// Langkah di layar jam buka; setelah selesai owner kembali ke layar tersebut
+	stepAwaitHours       = "await_hours"
+	stepAwaitHolidays    = "await_holidays"
+	stepAwaitTimezone    = "await_timezone"
+	stepAwaitAwayPrompt  = "await_away_prompt"
+	stepAwaitAwayMessage = "await_away_message"
+)
+
+// Jenis input yang diterima oleh sebuah langkah dialog.
+const (
+	inputText     = "text"
+	inputSecret   = "secret"
+	inputURL      = "url"
+	inputLocation = "location"
+)
+
+// dialogTimeout adalah batas waktu owner mengirim input sebelum dialog dibatalkan otomatis.
+const dialogTimeout = 10 * time.Minute
+
+// dialogPromptKeys memetakan langkah dialog ke teks permintaan input di locale.
+var dialogPromptKeys = map[string]string{
+	stepAwaitPrompt:    "prompt_input",
+	stepAwaitKey:       "key_input",
+	stepAwaitLocation:  "location_input",
+	stepAwaitBaseURL:   "base_url_input",
+	stepAwaitSpendCaps: "caps_input",
+	stepAwaitVars:      "vars_input",
+
+	stepAwaitHours:       "hours_input",
+	stepAwaitHolidays:    "holidays_input",
+	stepAwaitTimezone:    "timezone_input",
+	stepAwaitAwayPrompt:  "away_prompt_input",
+	stepAwaitAwayMessage: "away_message_input",
+}
+
+// hoursSteps adalah langkah dialog milik layar jam buka.
+var hoursSteps = map[string]bool{
+	stepAwaitHours:       true,
+	stepAwaitHolidays:    true,
+	stepAwaitTimezone:    true,
+	stepAwaitAwayPrompt:  true,
+	stepAwaitAwayMessage: true,
+}
+
+// providerBackup menyimpan pengaturan provider sebelum owner berpindah provider, supaya bisa
+// dipulihkan jika input base URL dibatalkan atau kedaluwarsa.
+type providerBackup struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	EncryptedKey string `json:"encrypted_key"`
+	KeyStatus    string `json:"key_status"`
+	BaseURL      string `json:"base_url"`
+}
+
+// getDialog mengembalikan dialog aktif owner, atau nil jika tidak ada.
+func (h *BotHandler) getDialog(telegramID int64) (*models.DialogState, error) {
+	var state *models.DialogState
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		state, err = h.DB.GetDialogState(telegramID)
+		return err
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	return state, err
+}
+
+// startDialog menyimpan langkah baru dan menampilkan permintaan input di pesan dashboard.
+func (h *BotHandler) startDialog(user *models.User, step, expect, backup string, dashboardID int64) error {
+	state := models.DialogState{
+		TelegramID:  user.TelegramID,
+		Step:        step,
+		Expect:      expect,
+		ExpiresAt:   time.Now().Add(dialogTimeout),
+		Backup:      backup,
+		DashboardID: dashboardID,
+	}
+	err := database.Retry(dbRetryAttempts, func() error {
+		return h.DB.SaveDialogState(state)
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		h.refreshDashboard(user.TelegramID, user, h.storageErrorText(user.Language, err))
+		return err
+	}
+	h.showDialogPrompt(user.TelegramID, &state, user.Language, "")
+	return nil
+}
+
+// showDialogPrompt menampilkan permintaan input (dengan catatan opsional) dan tombol batal.
+func (h *BotHandler) showDialogPrompt(chatID int64, state *models.DialogState, lang, note string) {
+	text := h.I18n.Get(lang, dialogPromptKeys[state.Step])
+	if note != "" {
+		text = note + "\n\n" + text
+	}
+	markup := map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{{"text": h.I18n.Get(lang, "btn_cancel"), "callback_data": "back_main"}},
+		},
+	}
+	h.TG.EditMessage(chatID, state.DashboardID, text, markup)
+}
+
+// finishDialog menghapus dialog yang sudah selesai tanpa memulihkan backup.
+func (h *BotHandler) finishDialog(telegramID int64) {
+	err := database.Retry(dbRetryAttempts, func() error {
+		return h.DB.ClearDialogState(telegramID)
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+	}
+}
+
+// cancelDialog memulihkan nilai backup (jika ada) lalu menghapus dialog.
+func (h *BotHandler) cancelDialog(user *models.User, state *models.DialogState) {
+	if state.Step == stepAwaitBaseURL && state.Backup != "" {
+		var backup providerBackup
+		if err := json.Unmarshal([]byte(state.Backup), &backup); err == nil {
+			user.AIProvider = backup.Provider
+			user.AIModel = backup.Model
+			user.EncryptedGroqKey = backup.EncryptedKey
+			user.KeyStatus = backup.KeyStatus
+			user.AIBaseURL = backup.BaseURL
+			h.saveUser(user)
+		}
+	}
+	h.finishDialog(user.TelegramID)
+}
+
+// handleDialogInput memproses pesan owner sesuai langkah dialog yang sedang aktif.
+func (h *BotHandler) handleDialogInput(msg *api.Message, user *models.User, state *models.DialogState) {
+	lang := user.Language
+	h.TG.DeleteMessage(msg.Chat.ID, msg.MessageID)
+
+	if state.Expired(time.Now()) {
+		h.cancelDialog(user, state)
+		h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(lang, "input_expired"))
+		return
+	}
+
+	// Tolak jenis input yang salah tanpa membatalkan dialog
+	if (state.Expect == inputLocation) != (msg.Location != nil) || (state.Expect != inputLocation && strings.TrimSpace(msg.Text) == "") {
+		h.showDialogPrompt(msg.Chat.ID, state, lang, h.I18n.Get(lang, "input_wrong_type"))
+		return
+	}
+
+	status := ""
+	switch state.Step {
+	case stepAwaitLocation:
+		user.Latitude = msg.Location.Latitude
+		user.Longitude = msg.Location.Longitude
+		user.BusinessLocation = fmt.Sprintf("https://www.google.com/maps?q=%f,%f", msg.Location.Latitude, msg.Location.Longitude)
+		status = h.I18n.Get(lang, "location_success")
+
+	case stepAwaitPrompt:
+		// Template yang tidak bisa dirender ditolak; dialog tetap aktif untuk dikirim ulang
+		if err := h.validatePrompt(user, msg.Text); err != nil {
+			h.showDialogPrompt(msg.Chat.ID, state, lang, fmt.Sprintf(h.I18n.Get(lang, "template_invalid"), html.EscapeString(err.Error())))
+			return
+		}
+		user.SystemPrompt = msg.Text
+		status = "✅ <b>Prompt Updated!</b>"
+
+	case stepAwaitVars:
+		if reason, ok := applyPromptVars(user, msg.Text); !ok {
+			h.showDialogPrompt(msg.Chat.ID, state, lang, fmt.Sprintf(h.I18n.Get(lang, "vars_invalid"), reason))
+			return
+		}
+		status = h.I18n.Get(lang, "vars_saved")
+
+	case stepAwaitBaseURL:
+		baseURL := strings.TrimSpace(msg.Text)
+		if !validBaseURL(baseURL) {
+			h.cancelDialog(user, state)
+			h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(lang, "base_url_invalid"))
+			return
+		}
+		user.AIBaseURL = baseURL
+		status = h.I18n.Get(lang, "base_url_success")
+
+	case stepAwaitSpendCaps:
+		daily, monthly, ok := parseSpendCaps(msg.Text)
+		if !ok {
+			h.showDialogPrompt(msg.Chat.ID, state, lang, h.I18n.Get(lang, "caps_invalid"))
+			return
+		}
+		user.DailySpendCap = daily
+		user.MonthlySpendCap = monthly
+		status = fmt.Sprintf(h.I18n.Get(lang, "caps_success"), h.formatCap(lang, daily), h.formatCap(lang, monthly))
+
+	case stepAwaitHours, stepAwaitHolidays, stepAwaitTimezone, stepAwaitAwayPrompt, stepAwaitAwayMessage:
+		if state.Step == stepAwaitAwayPrompt || state.Step == stepAwaitAwayMessage {
+			if err := h.validatePrompt(user, msg.Text); err != nil {
+				h.showDialogPrompt(msg.Chat.ID, state, lang, fmt.Sprintf(h.I18n.Get(lang, "template_invalid"), html.EscapeString(err.Error())))
+				return
+			}
+		}
+		if reason, ok := applyHoursInput(user, state.Step, msg.Text); !ok {
+			h.showDialogPrompt(msg.Chat.ID, state, lang, fmt.Sprintf(h.I18n.Get(lang, "hours_invalid"), reason))
+			return
+		}
+		status = h.I18n.Get(lang, "hours_saved")
+
+	case stepAwaitKey:
+		// Hanya key Groq yang punya format tetap; provider lain cukup tidak kosong
+		key := strings.TrimSpace(msg.Text)
+		if providerOf(user) == api.ProviderGroq && !strings.HasPrefix(key, "gsk_") {
+			h.finishDialog(user.TelegramID)
+			h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(lang, "key_invalid"))
+			return
+		}
+		// Cek key ke provider sebelum disimpan; key yang ditolak tidak pernah disimpan
+		keyStatus := h.checkKey(user, key, false)
+		if keyStatus == keyStatusInvalid {
+			h.finishDialog(user.TelegramID)
+			h.refreshDashboard(msg.Chat.ID, user, h.I18n.Get(lang, "key_rejected"))
+			return
+		}
+		enc, err := h.Keys.Encrypt(key, encryption.Binding(user.TelegramID, models.SecretAPIKey))
+		if err != nil {
+			log.Printf("Encryption Error: %v", err)
+			return
+		}
+		user.EncryptedGroqKey = enc
+		user.KeyStatus = keyStatus
+		user.KeyCheckedAt = time.Now().UTC().Format(time.RFC3339)
+		status = h.I18n.Get(lang, "key_success")
+		if keyStatus != keyStatusValid {
+			// Key tersimpan tetapi belum bisa dipastikan (rate limit atau jaringan)
+			status = h.I18n.Get(lang, "key_saved_unverified") + "\n" + h.I18n.Get(lang, "key_status_"+keyStatus)
+		}
+
+	default:
+		h.finishDialog(user.TelegramID)
+		return
+	}
+
+	if err := h.saveUser(user); err != nil {
+		// Dialog dibiarkan aktif supaya owner bisa mengirim ulang inputnya
+		h.showDialogPrompt(msg.Chat.ID, state, lang, h.storageErrorText(lang, err))
+		return
+	}
+	h.finishDialog(user.TelegramID)
+	if hoursSteps[state.Step] && state.DashboardID != 0 {
+		h.showHours(msg.Chat.ID, state.DashboardID, user, status)
+		return
+	}
+	if state.Step == stepAwaitVars && state.DashboardID != 0 {
+		h.showPromptVars(msg.Chat.ID, state.DashboardID, user, status)
+		return
+	}
+	h.refreshDashboard(msg.Chat.ID, user, status)
+}
+
+// RunDialogJanitor membatalkan dialog yang ditinggalkan owner setiap interval sampai ctx selesai.
+func (h *BotHandler) RunDialogJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.expireDialogs()
+		}
+	}
+}
+
+func (h *BotHandler) expireDialogs() {
+	states, err := h.DB.ListExpiredDialogStates(time.Now())
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		return
+	}
+	for i := range states {
+		state := &states[i]
+		user, err := h.DB.GetUser(state.TelegramID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				h.finishDialog(state.TelegramID)
+			}
+			continue
+		}
+		h.cancelDialog(user, state)
+		if state.DashboardID != 0 {
+			user.LastDashboardID = state.DashboardID
+		}
+		h.refreshDashboard(user.TelegramID, user, h.I18n.Get(user.Language, "input_expired"))
+	}
+}
+
+// legacyWaitPrefixes adalah penanda input lama yang dulu disisipkan langsung ke data user.
+var legacyWaitPrefixes = []string{"WAIT_FOR_PROMPT:", "WAIT_FOR_KEY:", "WAIT_FOR_LOCATION:", "WAIT_FOR_BASEURL:"}
+
+// migrateLegacyWait membersihkan penanda WAIT_ lama dari data user dan mengembalikan nilai
+// aslinya. Mengembalikan true jika ada field yang berubah dan perlu disimpan.
+func migrateLegacyWait(user *models.User) bool {
+	changed := false
+	for _, field := range []*string{&user.SystemPrompt, &user.EncryptedGroqKey, &user.BusinessLocation, &user.AIBaseURL} {
+		for _, prefix := range legacyWaitPrefixes {
+			if strings.HasPrefix(*field, prefix) {
+				*field = strings.TrimPrefix(*field, prefix)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
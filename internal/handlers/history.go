@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/models"
+	"unicode/utf8"
+)
+
+// historyFetchLimit adalah jumlah pesan terbaru yang diambil dari database sebelum dipangkas
+// sesuai anggaran token model.
+const historyFetchLimit = 200
+
+// Anggaran konteks default untuk model yang context window-nya tidak dilaporkan provider.
+// Ollama memakai num_ctx kecil secara default, jadi server lokal diberi anggaran lebih kecil.
+const (
+	defaultContextWindow = 8192
+	localContextWindow   = 4096
+)
+
+// messageOverheadTokens memperkirakan token tambahan per pesan (role dan pemisah).
+const messageOverheadTokens = 4
+
+// minTruncatedTokens: sisa anggaran yang lebih kecil dari ini tidak dipakai untuk potongan pesan.
+const minTruncatedTokens = 32
+
+// runeTokens memperkirakan biaya token satu karakter. Teks Latin rata-rata ~4 karakter per
+// token; huruf non-Latin (Kiril, emoji, dll.) jauh lebih mahal, jadi dihitung lebih besar
+// supaya perkiraan tidak pernah terlalu rendah.
+func runeTokens(r rune) float64 {
+	if r < utf8.RuneSelf {
+		return 0.25
+	}
+	return 0.5
+}
+
+// estimateTokens memperkirakan jumlah token teks tanpa tokenizer milik provider.
+func estimateTokens(text string) int {
+	total := 0.0
+	for _, r := range text {
+		total += runeTokens(r)
+	}
+	return int(total) + 1
+}
+
+func messageTokens(m models.ChatMessage) int {
+	return estimateTokens(m.Content) + messageOverheadTokens
+}
+
+// truncateTail memotong awal teks sehingga sisa ekornya muat dalam budget token. Bagian
+// akhir dipertahankan karena paling dekat dengan percakapan saat ini.
+func truncateTail(text string, budget int) string {
+	used := 1.0 // ruang untuk penanda "…"
+	start := len(text)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if used+runeTokens(r) > float64(budget) {
+			break
+		}
+		used += runeTokens(r)
+		start -= size
+	}
+	if start == 0 {
+		return text
+	}
+	return "…" + text[start:]
+}
+
+// contextWindowFor mengembalikan context window model owner dari katalog (cache), atau
+// default jika katalog tidak tersedia. Anggaran dibatasi HistoryMaxTokens agar biaya
+// per balasan tetap wajar.
+func (h *BotHandler) contextWindowFor(owner *models.User) int {
+	window := defaultContextWindow
+	if providerOf(owner) == api.ProviderLocal {
+		window = localContextWindow
+	}
+	if list, err := h.getModels(owner, false); err == nil {
+		for _, m := range list {
+			if m.ID == owner.AIModel && m.ContextWindow > 0 {
+				window = m.ContextWindow
+				break
+			}
+		}
+	}
+	if h.HistoryMaxTokens > 0 && window > h.HistoryMaxTokens {
+		window = h.HistoryMaxTokens
+	}
+	return window
+}
+
+// fitHistory memilih pesan terbaru yang muat dalam context window setelah dikurangi system
+// prompt dan ruang balasan. Pesan tertua dibuang lebih dulu; pesan pertama yang tidak muat
+// dipotong jika sisa anggarannya masih berarti. Pesan terbaru selalu disertakan.
+func fitHistory(systemPrompt string, history []models.ChatMessage, window, replyReserve int) []models.ChatMessage {
+	budget := window - replyReserve - estimateTokens(systemPrompt) - messageOverheadTokens
+	start := len(history)
+	var truncated *models.ChatMessage
+	for i := len(history) - 1; i >= 0; i-- {
+		cost := messageTokens(history[i])
+		if cost <= budget {
+			budget -= cost
+			start = i
+			continue
+		}
+		remaining := budget - messageOverheadTokens
+		if i == len(history)-1 || remaining >= minTruncatedTokens {
+			if remaining < minTruncatedTokens {
+				remaining = minTruncatedTokens
+			}
+			m := history[i]
+			m.Content = truncateTail(m.Content, remaining)
+			truncated = &m
+		}
+		break
+	}
+
+	fitted := make([]models.ChatMessage, 0, len(history)-start+1)
+	if truncated != nil {
+		fitted = append(fitted, *truncated)
+	}
+	return append(fitted, history[start:]...)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"tg-business-bot/internal/models"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 1},
+		{"abcd", 2},
+		{"abcdefgh", 3},
+		{"привет", 4},
+		{"😀😀", 2},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFitHistory(t *testing.T) {
+	// Pesan 40 karakter ASCII = 11 token + overhead 4 = 15
+	short := func(c string) models.ChatMessage {
+		return models.ChatMessage{Role: "user", Content: strings.Repeat(c, 40)}
+	}
+	long := models.ChatMessage{Role: "user", Content: strings.Repeat("x", 400)}
+
+	tests := []struct {
+		name    string
+		history []models.ChatMessage
+		window  int
+		reserve int
+		want    []string
+	}{
+		{"empty history", nil, 100, 0, []string{}},
+		{"everything fits", []models.ChatMessage{short("a"), short("b"), short("c")}, 100, 0,
+			[]string{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 40)}},
+		{"oldest dropped", []models.ChatMessage{short("a"), short("b"), short("c")}, 40, 0,
+			[]string{strings.Repeat("b", 40), strings.Repeat("c", 40)}},
+		{"reply reserve counts", []models.ChatMessage{short("a"), short("b"), short("c")}, 100, 60,
+			[]string{strings.Repeat("b", 40), strings.Repeat("c", 40)}},
+		{"oldest truncated when room is left", []models.ChatMessage{long, short("c")}, 100, 0,
+			[]string{"…" + strings.Repeat("x", 300), strings.Repeat("c", 40)}},
+		{"newest always kept", []models.ChatMessage{short("a"), long}, 20, 0,
+			[]string{"…" + strings.Repeat("x", 124)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitHistory("", tt.history, tt.window, tt.reserve)
+			contents := make([]string, len(got))
+			for i, m := range got {
+				contents[i] = m.Content
+			}
+			if strings.Join(contents, "|") != strings.Join(tt.want, "|") || len(contents) != len(tt.want) {
+				t.Errorf("fitHistory = %q, want %q", contents, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitHistoryCountsSystemPrompt(t *testing.T) {
+	history := []models.ChatMessage{{Role: "user", Content: "old"}, {Role: "user", Content: "new"}}
+	// 400 karakter system prompt = 101 token, hanya pesan terbaru yang masih muat
+	got := fitHistory(strings.Repeat("s", 400), history, 112, 0)
+	if len(got) != 1 || got[0].Content != "new" {
+		t.Errorf("fitHistory = %+v, want only the newest message", got)
+	}
+}
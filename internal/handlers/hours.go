@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/format"
+	"tg-business-bot/internal/models"
+	"tg-business-bot/internal/schedule"
+	"time"
+)
+
+// Perilaku bot di luar jam buka (models.User.AwayMode).
+const (
+	awayModeAI      = ""        // AI membalas seperti biasa
+	awayModePrompt  = "prompt"  // AI membalas dengan AwayPrompt
+	awayModeMessage = "message" // Kirim AwayMessage sekali per periode tutup
+	awayModeSilent  = "silent"  // Tidak membalas
+)
+
+// awayModes berurutan seperti tombol di layar jam buka; "ai" dipakai di callback untuk mode kosong.
+var awayModes = []struct{ mode, callback string }{
+	{awayModeAI, "ai"},
+	{awayModePrompt, awayModePrompt},
+	{awayModeMessage, awayModeMessage},
+	{awayModeSilent, awayModeSilent},
+}
+
+// clearInput adalah input dialog untuk mengosongkan pengaturan.
+const clearInput = "-"
+
+// ownerSchedule mengurai jadwal owner. Jadwal tersimpan sudah divalidasi saat input, jadi
+// kegagalan di sini hanya dicatat dan bisnis dianggap selalu buka.
+func ownerSchedule(owner *models.User) *schedule.Schedule {
+	sched, err := schedule.Parse(owner.BusinessHours, owner.Holidays, owner.Timezone)
+	if err != nil {
+		log.Printf("Warning: invalid business hours of owner %d: %v", owner.TelegramID, err)
+		return nil
+	}
+	return sched
+}
+
+// openStatus mengembalikan nilai placeholder {{is_open}} dan {{next_opening}}; waktu buka
+// ditulis di zona waktu owner dengan nama hari dan bulan dalam bahasa owner.
+func (h *BotHandler) openStatus(owner *models.User, now time.Time) (string, string) {
+	sched := ownerSchedule(owner)
+	if sched.IsOpen(now) {
+		return "open", "now"
+	}
+	next, ok := sched.NextOpening(now)
+	if !ok {
+		return "closed", "unknown"
+	}
+	return "closed", h.localOpening(owner.Language, next)
+}
+
+// awayAction menentukan balasan di luar jam buka. handled bernilai true jika balasan AI
+// tidak perlu dibuat (diam atau pesan tetap sudah dikirim); selain itu prompt adalah prompt
+// bisnis yang dipakai AI.
+func (h *BotHandler) awayAction(owner *models.User, msg *api.Message, displayName string) (prompt string, handled bool) {
+	prompt = owner.SystemPrompt
+	now := time.Now()
+	sched := ownerSchedule(owner)
+	if sched.IsOpen(now) {
+		return prompt, false
+	}
+
+	switch owner.AwayMode {
+	case awayModeSilent:
+		return "", true
+	case awayModeMessage:
+		h.sendAwayMessage(owner, msg, displayName, sched, now)
+		return "", true
+	case awayModePrompt:
+		if owner.AwayPrompt != "" {
+			prompt = owner.AwayPrompt
+		}
+	}
+	return prompt, false
+}
+
+// sendAwayMessage mengirim pesan tetap paling banyak sekali per pelanggan per periode tutup,
+// supaya pelanggan yang mengirim beberapa pesan tidak menerima pesan yang sama berulang kali.
+func (h *BotHandler) sendAwayMessage(owner *models.User, msg *api.Message, displayName string, sched *schedule.Schedule, now time.Time) {
+	key := [2]int64{owner.TelegramID, msg.Chat.ID}
+	next, ok := sched.NextOpening(now)
+	if !ok {
+		// Tanpa jadwal buka, ulangi paling cepat sehari sekali
+		next = now.Add(24 * time.Hour)
+	}
+	h.awayMu.Lock()
+	if h.awaySent[key].After(now) {
+		h.awayMu.Unlock()
+		return
+	}
+	// Entri yang periode tutupnya sudah lewat tidak dipakai lagi; buang di sini supaya map tidak
+	// tumbuh terus dengan setiap pelanggan yang pernah menerima pesan ini
+	for k, until := range h.awaySent {
+		if !until.After(now) {
+			delete(h.awaySent, k)
+		}
+	}
+	h.awaySent[key] = next
+	h.awayMu.Unlock()
+
+	text := owner.AwayMessage
+	if text == "" {
+		text = h.I18n.Get(owner.Language, "away_default_message")
+	}
+	text = format.Sanitize(h.processPlaceholders(text, owner, msg))
+
+	err := database.Retry(dbRetryAttempts, func() error {
+		return h.DB.SaveMessage(owner.TelegramID, msg.Chat.ID, displayName, "assistant", text)
+	})
+	if err != nil {
+		log.Printf("Storage Error: away message in chat %d: %v", msg.Chat.ID, err)
+	}
+	h.deliverReply(owner, msg, text, 0)
+}
+
+// awayModeName mengembalikan label mode di luar jam buka.
+func (h *BotHandler) awayModeName(lang, mode string) string {
+	for _, m := range awayModes {
+		if m.mode == mode {
+			return h.I18n.Get(lang, "away_mode_"+m.callback)
+		}
+	}
+	return mode
+}
+
+// showHours menampilkan jam buka, hari libur, zona waktu dan mode di luar jam buka.
+func (h *BotHandler) showHours(chatID, messageID int64, user *models.User, note string) {
+	lang := user.Language
+
+	tz := user.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	hours := h.I18n.Get(lang, "hours_always_open")
+	if sched := ownerSchedule(user); sched != nil {
+		hours = strings.Join(sched.Lines(), "\n")
+	}
+	holidays := strings.TrimSpace(user.Holidays)
+	if holidays == "" {
+		holidays = h.I18n.Get(lang, "hours_no_holidays")
+	}
+	isOpen, next := h.openStatus(user, time.Now())
+	status := h.I18n.Get(lang, "hours_open_now")
+	if isOpen != "open" {
+		status = fmt.Sprintf(h.I18n.Get(lang, "hours_closed_now"), next)
+	}
+
+	text := fmt.Sprintf(h.I18n.Get(lang, "hours_title"),
+		html.EscapeString(tz), html.EscapeString(hours), html.EscapeString(holidays),
+		h.awayModeName(lang, user.AwayMode), status)
+	if note != "" {
+		text = note + "\n\n" + text
+	}
+
+	rows := [][]map[string]interface{}{
+		{
+			{"text": h.I18n.Get(lang, "btn_set_hours"), "callback_data": "hours_set"},
+			{"text": h.I18n.Get(lang, "btn_set_holidays"), "callback_data": "hours_holidays"},
+		},
+	}
+	settings := []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_set_timezone"), "callback_data": "hours_tz"}}
+	switch user.AwayMode {
+	case awayModePrompt:
+		settings = append(settings, map[string]interface{}{"text": h.I18n.Get(lang, "btn_away_prompt"), "callback_data": "hours_away_text"})
+	case awayModeMessage:
+		settings = append(settings, map[string]interface{}{"text": h.I18n.Get(lang, "btn_away_message"), "callback_data": "hours_away_text"})
+	}
+	rows = append(rows, settings)
+
+	var modeRow []map[string]interface{}
+	for _, m := range awayModes {
+		label := h.I18n.Get(lang, "away_mode_"+m.callback)
+		if m.mode == user.AwayMode {
+			label = "✅ " + label
+		}
+		modeRow = append(modeRow, map[string]interface{}{"text": label, "callback_data": "away_mode_" + m.callback})
+		if len(modeRow) == 2 {
+			rows = append(rows, modeRow)
+			modeRow = nil
+		}
+	}
+	rows = append(rows, []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}})
+
+	h.TG.EditMessage(chatID, messageID, text, map[string]interface{}{"inline_keyboard": rows})
+}
+
+// handleHoursCallback memproses tombol di layar jam buka.
+func (h *BotHandler) handleHoursCallback(cb *api.CallbackQuery, user *models.User) {
+	messageID := cb.Msg.MessageID
+	switch {
+	case cb.Data == "menu_hours":
+		h.showHours(cb.From.ID, messageID, user, "")
+	case cb.Data == "hours_set":
+		h.startDialog(user, stepAwaitHours, inputText, "", messageID)
+	case cb.Data == "hours_holidays":
+		h.startDialog(user, stepAwaitHolidays, inputText, "", messageID)
+	case cb.Data == "hours_tz":
+		h.showTimezones(cb.From.ID, messageID, user)
+	case cb.Data == "hours_tz_other":
+		h.startDialog(user, stepAwaitTimezone, inputText, "", messageID)
+	case strings.HasPrefix(cb.Data, "hours_tz_set_"):
+		note := h.I18n.Get(user.Language, "timezone_saved")
+		tz := strings.TrimPrefix(cb.Data, "hours_tz_set_")
+		if _, err := schedule.LoadLocation(tz); err != nil {
+			note = fmt.Sprintf(h.I18n.Get(user.Language, "hours_invalid"), html.EscapeString(err.Error()))
+		} else {
+			user.Timezone = tz
+			if err := h.saveUser(user); err != nil {
+				note = h.storageErrorText(user.Language, err)
+			}
+		}
+		h.showHours(cb.From.ID, messageID, user, note)
+	case cb.Data == "hours_away_text" && user.AwayMode == awayModeMessage:
+		h.startDialog(user, stepAwaitAwayMessage, inputText, "", messageID)
+	case cb.Data == "hours_away_text":
+		h.startDialog(user, stepAwaitAwayPrompt, inputText, "", messageID)
+	case strings.HasPrefix(cb.Data, "away_mode_"):
+		for _, m := range awayModes {
+			if cb.Data == "away_mode_"+m.callback {
+				user.AwayMode = m.mode
+			}
+		}
+		note := ""
+		if err := h.saveUser(user); err != nil {
+			note = h.storageErrorText(user.Language, err)
+		}
+		h.showHours(cb.From.ID, messageID, user, note)
+	}
+}
+
+// applyHoursInput memvalidasi dan menyimpan input dialog jam buka ke user. "-" mengosongkan
+// pengaturan. Mengembalikan teks error (sudah di-escape) jika input tidak valid.
+func applyHoursInput(user *models.User, step, input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	if input == clearInput {
+		input = ""
+	}
+	var err error
+	switch step {
+	case stepAwaitHours:
+		if _, err = schedule.Parse(input, user.Holidays, user.Timezone); err == nil {
+			user.BusinessHours = input
+		}
+	case stepAwaitHolidays:
+		if _, err = schedule.ParseHolidays(input); err == nil {
+			user.Holidays = input
+		}
+	case stepAwaitTimezone:
+		if _, err = schedule.LoadLocation(input); err == nil {
+			user.Timezone = input
+		}
+	case stepAwaitAwayPrompt:
+		user.AwayPrompt = input
+	case stepAwaitAwayMessage:
+		user.AwayMessage = input
+	}
+	if err != nil {
+		return html.EscapeString(err.Error()), false
+	}
+	return "", true
+}
+
+// showTimezones menampilkan pilihan cepat zona waktu beserta jam setempat saat ini.
+func (h *BotHandler) showTimezones(chatID, messageID int64, user *models.User) {
+	lang := user.Language
+	now := time.Now()
+	var rows [][]map[string]interface{}
+	var row []map[string]interface{}
+	for _, tz := range commonTimezones {
+		loc, err := schedule.LoadLocation(tz)
+		if err != nil {
+			continue
+		}
+		label := tz + " · " + now.In(loc).Format("15:04")
+		if tz == user.Timezone || (tz == "UTC" && user.Timezone == "") {
+			label = "✅ " + label
+		}
+		row = append(row, map[string]interface{}{"text": label, "callback_data": "hours_tz_set_" + tz})
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if row != nil {
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		[]map[string]interface{}{{"text": h.I18n.Get(lang, "btn_timezone_other"), "callback_data": "hours_tz_other"}},
+		[]map[string]interface{}{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "menu_hours"}},
+	)
+	h.TG.EditMessage(chatID, messageID, h.I18n.Get(lang, "timezone_pick"), map[string]interface{}{"inline_keyboard": rows})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"testing"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/i18n"
+	"tg-business-bot/internal/models"
+	"tg-business-bot/internal/schedule"
+	"time"
+)
+
+func TestSendAwayMessageOncePerClosedPeriod(t *testing.T) {
+	tg := &fakeTelegram{}
+	h := NewBotHandler(database.NewMemoryStore(), tg, i18n.NewBundle(), nil)
+	owner := &models.User{TelegramID: 1, AwayMode: awayModeMessage, AwayMessage: "We are closed"}
+	sched, err := schedule.Parse("daily 09:00-17:00", "", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &api.Message{Chat: &api.Chat{ID: 10}, From: &api.User{ID: 10}}
+
+	night := time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC)
+	// Pelanggan lain yang periode tutupnya sudah lewat
+	h.awaySent[[2]int64{1, 99}] = night.Add(-time.Hour)
+
+	h.sendAwayMessage(owner, msg, "Customer", sched, night)
+	h.sendAwayMessage(owner, msg, "Customer", sched, night.Add(time.Hour))
+	if len(tg.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1 per closed period", len(tg.sent))
+	}
+	if _, ok := h.awaySent[[2]int64{1, 99}]; ok || len(h.awaySent) != 1 {
+		t.Fatalf("awaySent = %v, want only the current customer", h.awaySent)
+	}
+
+	// Periode tutup berikutnya mengirim lagi
+	h.sendAwayMessage(owner, msg, "Customer", sched, night.Add(24*time.Hour))
+	if len(tg.sent) != 2 {
+		t.Fatalf("sent %d messages after the next closed period, want 2", len(tg.sent))
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/encryption"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// Status API key owner setelah dicek ke provider.
+const (
+	keyStatusValid        = "valid"
+	keyStatusInvalid      = "invalid"
+	keyStatusRevoked      = "revoked"
+	keyStatusRateLimited  = "rate_limited"
+	keyStatusNetworkError = "network_error"
+)
+
+// keyStatusFromError menerjemahkan hasil panggilan /models ke status key. Key yang sebelumnya
+// valid lalu ditolak dianggap dicabut (revoked), bukan salah ketik.
+func keyStatusFromError(err error, wasValid bool) string {
+	switch {
+	case err == nil:
+		return keyStatusValid
+	case errors.Is(err, api.ErrUnauthorized), errors.Is(err, api.ErrForbidden):
+		if wasValid {
+			return keyStatusRevoked
+		}
+		return keyStatusInvalid
+	case errors.Is(err, api.ErrRateLimited):
+		// 429 hanya dikirim setelah key berhasil diautentikasi
+		return keyStatusRateLimited
+	}
+	return keyStatusNetworkError
+}
+
+// checkKey melakukan panggilan murah yang terautentikasi (daftar model) dengan key mentah.
+func (h *BotHandler) checkKey(owner *models.User, key string, wasValid bool) string {
+	provider, err := h.chatProviderWithKey(owner, key)
+	if err != nil {
+		log.Printf("Key Check Error: owner %d: %v", owner.TelegramID, err)
+		return keyStatusInvalid
+	}
+	_, err = provider.ListModels()
+	if err != nil {
+		log.Printf("Key Check: owner %d: %v", owner.TelegramID, err)
+	}
+	return keyStatusFromError(err, wasValid)
+}
+
+// keyStatusText menampilkan status key di dashboard.
+func (h *BotHandler) keyStatusText(user *models.User) string {
+	lang := user.Language
+	if !providerNeedsKey(providerOf(user)) {
+		return h.I18n.Get(lang, "key_not_needed")
+	}
+	if h.usesSharedKey(user) {
+		return h.sharedKeyText(user)
+	}
+	if user.EncryptedGroqKey == "" {
+		return h.I18n.Get(lang, "key_not_set")
+	}
+	status := h.I18n.Get(lang, "key_status_"+user.KeyStatus)
+	if user.KeyStatus == "" {
+		// Key lama yang belum pernah dicek
+		status = h.I18n.Get(lang, "key_set")
+	}
+	if h.SharedKey != "" && providerOf(user) == api.ProviderGroq {
+		// Key platform tersedia, jadi tunjukkan bahwa key milik owner yang sedang dipakai
+		status = h.I18n.Get(lang, "key_own") + " · " + status
+	}
+	return status
+}
+
+// updateKeyStatus menyimpan status key baru dan memberi tahu owner jika key tidak lagi bisa dipakai.
+// owner bisa berupa snapshot lama (cek berkala memakai hasil ListUsers), jadi hanya kolom status
+// yang ditulis, dan hanya jika key yang dicek masih tersimpan. Jika key sudah diganti selama
+// pengecekan, hasilnya tidak berlaku lagi.
+func (h *BotHandler) updateKeyStatus(owner *models.User, status string) {
+	checkedAt := time.Now().UTC().Format(time.RFC3339)
+	var updated bool
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		updated, err = h.DB.UpdateKeyStatus(owner.TelegramID, owner.EncryptedGroqKey, status, checkedAt)
+		return err
+	})
+	if err != nil {
+		log.Printf("Storage Error: key status of owner %d: %v", owner.TelegramID, err)
+		return
+	}
+	if !updated {
+		return
+	}
+	previous := owner.KeyStatus
+	owner.KeyStatus, owner.KeyCheckedAt = status, checkedAt
+
+	if status != previous && (status == keyStatusInvalid || status == keyStatusRevoked) {
+		text := h.I18n.Get(owner.Language, "key_stopped_working") + "\n\n" + h.I18n.Get(owner.Language, "key_status_"+status)
+		h.notifyOwner(owner, text)
+	}
+}
+
+// RunKeyChecker mengecek ulang semua key yang tersimpan setiap interval sampai ctx selesai.
+func (h *BotHandler) RunKeyChecker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.recheckKeys(ctx)
+		}
+	}
+}
+
+func (h *BotHandler) recheckKeys(ctx context.Context) {
+	users, err := h.DB.ListUsers()
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		return
+	}
+	for i := range users {
+		user := &users[i]
+		if user.EncryptedGroqKey == "" || !providerNeedsKey(providerOf(user)) {
+			continue
+		}
+		key, err := h.Keys.Decrypt(user.EncryptedGroqKey, encryption.Binding(user.TelegramID, models.SecretAPIKey))
+		if err != nil {
+			log.Printf("Key Check Error: owner %d: cannot decrypt key: %v", user.TelegramID, err)
+			continue
+		}
+
+		wasValid := user.KeyStatus == keyStatusValid || user.KeyStatus == keyStatusRateLimited || user.KeyStatus == ""
+		status := h.checkKey(user, key, wasValid)
+		if status != user.KeyStatus {
+			h.updateKeyStatus(user, status)
+		}
+
+		// Beri jeda antar owner supaya tidak membanjiri provider
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
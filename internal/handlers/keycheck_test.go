@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"tg-business-bot/internal/api"
+)
+
+func TestKeyStatusFromError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wasValid bool
+		want     string
+	}{
+		{"ok", nil, false, keyStatusValid},
+		{"rejected new key", api.ErrUnauthorized, false, keyStatusInvalid},
+		{"rejected valid key", api.ErrUnauthorized, true, keyStatusRevoked},
+		{"forbidden valid key", fmt.Errorf("list models: %w", api.ErrForbidden), true, keyStatusRevoked},
+		{"rate limited", fmt.Errorf("list models: %w", api.ErrRateLimited), false, keyStatusRateLimited},
+		{"network", errors.New("dial tcp: timeout"), true, keyStatusNetworkError},
+	}
+	for _, tt := range tests {
+		if got := keyStatusFromError(tt.err, tt.wasValid); got != tt.want {
+			t.Errorf("%s: keyStatusFromError = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/knowledge"
+	"tg-business-bot/internal/models"
+	"time"
+	"unicode/utf8"
+)
+
+// Batas knowledge base per owner. Bot API hanya bisa mengunduh file sampai 20 MB; batas
+// potongan menjaga indeks di memori dan query ListKnowledgeChunks tetap kecil.
+const (
+	maxKnowledgeFileBytes = 10 << 20
+	maxKnowledgeDocs      = 20
+	maxKnowledgeChunks    = 1000
+	maxKnowledgeNameRunes = 60
+)
+
+// knowledgeSearchLimit adalah jumlah potongan teratas yang dipertimbangkan per balasan.
+const knowledgeSearchLimit = 4
+
+// knowledgeQueryMessages: pesan pelanggan terakhir yang dipakai sebagai query, supaya
+// pertanyaan lanjutan seperti "berapa harganya?" tetap menemukan topik sebelumnya.
+const knowledgeQueryMessages = 2
+
+// knowledgeCacheTTL membatasi umur indeks di cache, sehingga perubahan dari instance lain
+// (beberapa replika webhook) tetap terbaca.
+const knowledgeCacheTTL = 10 * time.Minute
+
+// knowledgeCache menyimpan indeks BM25 per owner supaya potongan tidak dibaca ulang dari
+// database di setiap pesan pelanggan.
+type knowledgeCache struct {
+	mu      sync.Mutex
+	entries map[int64]knowledgeEntry
+}
+
+type knowledgeEntry struct {
+	index    *knowledge.Index
+	loadedAt time.Time
+}
+
+func newKnowledgeCache() *knowledgeCache {
+	return &knowledgeCache{entries: make(map[int64]knowledgeEntry)}
+}
+
+func (c *knowledgeCache) get(ownerID int64) *knowledge.Index {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[ownerID]
+	if !ok || time.Since(entry.loadedAt) > knowledgeCacheTTL {
+		return nil
+	}
+	return entry.index
+}
+
+func (c *knowledgeCache) put(ownerID int64, index *knowledge.Index) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ownerID] = knowledgeEntry{index: index, loadedAt: time.Now()}
+}
+
+func (c *knowledgeCache) invalidate(ownerID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, ownerID)
+}
+
+// knowledgeIndex mengembalikan indeks knowledge base owner dari cache, atau membangunnya
+// dari database. Owner tanpa dokumen mendapat indeks kosong yang juga di-cache.
+func (h *BotHandler) knowledgeIndex(ownerID int64) (*knowledge.Index, error) {
+	if index := h.knowledge.get(ownerID); index != nil {
+		return index, nil
+	}
+	var chunks []models.KnowledgeChunk
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		chunks, err = h.DB.ListKnowledgeChunks(ownerID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	index := knowledge.NewIndex(chunks)
+	h.knowledge.put(ownerID, index)
+	return index, nil
+}
+
+// knowledgeQuery menggabungkan pesan pelanggan terakhir dari history sebagai query pencarian.
+func knowledgeQuery(history []models.ChatMessage) string {
+	var parts []string
+	for i := len(history) - 1; i >= 0 && len(parts) < knowledgeQueryMessages; i-- {
+		if history[i].Role == "user" {
+			parts = append(parts, history[i].Content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// knowledgeContext mencari potongan knowledge base yang relevan dengan percakapan dan
+// menyusunnya sebagai tambahan system prompt. Anggaran dibatasi KnowledgeMaxTokens dan
+// seperempat context window supaya history tetap mendapat ruang. Kegagalan database hanya
+// membuat balasan tanpa knowledge base, jadi tidak menghentikan balasan.
+func (h *BotHandler) knowledgeContext(owner *models.User, history []models.ChatMessage, window int) string {
+	budget := h.KnowledgeMaxTokens
+	if window/4 < budget {
+		budget = window / 4
+	}
+	if budget <= 0 {
+		return ""
+	}
+	index, err := h.knowledgeIndex(owner.TelegramID)
+	if err != nil {
+		log.Printf("Storage Error: knowledge base of owner %d: %v", owner.TelegramID, err)
+		return ""
+	}
+	hits := index.Search(knowledgeQuery(history), knowledgeSearchLimit)
+	if len(hits) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, chunk := range hits {
+		cost := estimateTokens(chunk.Content) + messageOverheadTokens
+		if cost > budget {
+			// Potongan lain yang lebih pendek mungkin masih muat
+			continue
+		}
+		budget -= cost
+		n++
+		fmt.Fprintf(&b, "\n\n[%d] %s", n, chunk.Content)
+	}
+	if n == 0 {
+		return ""
+	}
+	return "\n\nExcerpts from the business's knowledge base that may be relevant to the customer's message. " +
+		"Use them for facts such as prices, menu items and policies. If they do not answer the question, do not invent details." + b.String()
+}
+
+// handleKnowledgeUpload menambahkan dokumen yang dikirim owner di chat pribadi ke knowledge base.
+func (h *BotHandler) handleKnowledgeUpload(msg *api.Message, user *models.User) {
+	lang := user.Language
+	doc := msg.Document
+	markup := map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{{"text": h.I18n.Get(lang, "btn_knowledge"), "callback_data": "menu_kb"}},
+			{{"text": h.I18n.Get(lang, "btn_dashboard"), "callback_data": "back_main"}},
+		},
+	}
+	reply := func(text string) {
+		h.TG.SendMessage(msg.Chat.ID, text, "", markup)
+	}
+
+	if !knowledge.Supported(doc.FileName, doc.MimeType) {
+		reply(h.I18n.Get(lang, "kb_unsupported"))
+		return
+	}
+	if doc.FileSize > maxKnowledgeFileBytes {
+		reply(fmt.Sprintf(h.I18n.Get(lang, "kb_too_large"), maxKnowledgeFileBytes>>20))
+		return
+	}
+
+	var docs []models.KnowledgeDocument
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		docs, err = h.DB.ListKnowledgeDocuments(user.TelegramID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		reply(h.storageErrorText(lang, err))
+		return
+	}
+	if len(docs) >= maxKnowledgeDocs {
+		reply(fmt.Sprintf(h.I18n.Get(lang, "kb_limit_docs"), maxKnowledgeDocs))
+		return
+	}
+	usedChunks := 0
+	for _, d := range docs {
+		usedChunks += d.ChunkCount
+	}
+
+	data, err := h.downloadDocument(doc.FileID)
+	if err != nil {
+		log.Printf("Warning: Failed to download document from owner %d: %v", user.TelegramID, err)
+		reply(h.I18n.Get(lang, "kb_download_failed"))
+		return
+	}
+	text, err := knowledge.Extract(doc.FileName, doc.MimeType, data)
+	if err != nil {
+		log.Printf("Warning: Failed to read document %q from owner %d: %v", doc.FileName, user.TelegramID, err)
+		switch {
+		case errors.Is(err, knowledge.ErrNoText):
+			reply(h.I18n.Get(lang, "kb_no_text"))
+		case errors.Is(err, knowledge.ErrUnsupported):
+			reply(h.I18n.Get(lang, "kb_unsupported"))
+		default:
+			reply(h.I18n.Get(lang, "kb_read_failed"))
+		}
+		return
+	}
+	chunks := knowledge.Chunk(text)
+	if usedChunks+len(chunks) > maxKnowledgeChunks {
+		reply(fmt.Sprintf(h.I18n.Get(lang, "kb_limit_chunks"), maxKnowledgeChunks-usedChunks, len(chunks)))
+		return
+	}
+
+	name := strings.TrimSpace(doc.FileName)
+	if name == "" {
+		name = "document"
+	}
+	if utf8.RuneCountInString(name) > maxKnowledgeNameRunes {
+		name = string([]rune(name)[:maxKnowledgeNameRunes-1]) + "…"
+	}
+	// Tidak di-Retry: insert yang sebenarnya berhasil bisa tersimpan dua kali
+	_, err = h.DB.SaveKnowledgeDocument(models.KnowledgeDocument{OwnerID: user.TelegramID, Name: name, CreatedAt: time.Now().UTC()}, chunks)
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		reply(h.storageErrorText(lang, err))
+		return
+	}
+	h.knowledge.invalidate(user.TelegramID)
+	reply(fmt.Sprintf(h.I18n.Get(lang, "kb_saved"), html.EscapeString(name), len(chunks)))
+}
+
+// downloadDocument mengunduh isi file Telegram dengan batas maxKnowledgeFileBytes.
+func (h *BotHandler) downloadDocument(fileID string) ([]byte, error) {
+	file, err := h.TG.GetFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.FilePath == "" {
+		return nil, fmt.Errorf("getFile returned no file path")
+	}
+	return h.TG.DownloadFile(file.FilePath, maxKnowledgeFileBytes)
+}
+
+// showKnowledge menampilkan dokumen di knowledge base owner dengan tombol hapus per dokumen.
+func (h *BotHandler) showKnowledge(chatID, messageID int64, user *models.User, note string) {
+	lang := user.Language
+	back := []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}}
+
+	var docs []models.KnowledgeDocument
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		docs, err = h.DB.ListKnowledgeDocuments(user.TelegramID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		h.TG.EditMessage(chatID, messageID, h.storageErrorText(lang, err), map[string]interface{}{
+			"inline_keyboard": [][]map[string]interface{}{back},
+		})
+		return
+	}
+
+	text := fmt.Sprintf(h.I18n.Get(lang, "kb_title"), maxKnowledgeFileBytes>>20)
+	if note != "" {
+		text = note + "\n\n" + text
+	}
+	if len(docs) == 0 {
+		text += "\n\n" + h.I18n.Get(lang, "kb_none")
+		h.TG.EditMessage(chatID, messageID, text, map[string]interface{}{
+			"inline_keyboard": [][]map[string]interface{}{back},
+		})
+		return
+	}
+
+	var b strings.Builder
+	var buttons [][]map[string]interface{}
+	for _, d := range docs {
+		b.WriteString("\n" + fmt.Sprintf(h.I18n.Get(lang, "kb_line"), html.EscapeString(d.Name), d.ChunkCount))
+		buttons = append(buttons, []map[string]interface{}{{"text": "🗑 " + d.Name, "callback_data": fmt.Sprintf("kb_del_%d", d.ID)}})
+	}
+	text += "\n\n" + fmt.Sprintf(h.I18n.Get(lang, "kb_count"), len(docs), maxKnowledgeDocs) + b.String()
+	buttons = append(buttons, back)
+	h.TG.EditMessage(chatID, messageID, text, map[string]interface{}{"inline_keyboard": buttons})
+}
+
+// deleteKnowledgeDocument menghapus dokumen dari callback "kb_del_<documentID>".
+func (h *BotHandler) deleteKnowledgeDocument(chatID, messageID int64, user *models.User, data string) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(data, "kb_del_"), 10, 64)
+	if err != nil {
+		h.showKnowledge(chatID, messageID, user, "")
+		return
+	}
+	err = database.Retry(dbRetryAttempts, func() error {
+		return h.DB.DeleteKnowledgeDocument(user.TelegramID, id)
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		h.showKnowledge(chatID, messageID, user, h.storageErrorText(user.Language, err))
+		return
+	}
+	h.knowledge.invalidate(user.TelegramID)
+	h.showKnowledge(chatID, messageID, user, h.I18n.Get(user.Language, "kb_deleted"))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/url"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/encryption"
+	"tg-business-bot/internal/models"
+)
+
+// defaultModels adalah model awal saat owner berpindah provider, sebelum memilih dari katalog.
+var defaultModels = map[string]string{
+	api.ProviderGroq:   "openai/gpt-oss-120b",
+	api.ProviderOpenAI: "gpt-4o-mini",
+	api.ProviderLocal:  "llama3.1",
+}
+
+// providerOf mengembalikan provider owner; user lama tanpa pilihan dianggap memakai Groq.
+func providerOf(user *models.User) string {
+	if user.AIProvider == "" {
+		return api.ProviderGroq
+	}
+	return user.AIProvider
+}
+
+// providerNeedsKey bernilai false untuk server lokal operator yang tidak memakai API key.
+func providerNeedsKey(provider string) bool {
+	return provider != api.ProviderLocal
+}
+
+// newChatProvider membuat klien AI sesuai pilihan owner dengan key yang sudah didekripsi,
+// atau dengan key platform jika owner belum punya key sendiri.
+func (h *BotHandler) newChatProvider(owner *models.User) (api.ChatProvider, error) {
+	var key string
+	if h.usesSharedKey(owner) {
+		key = h.SharedKey
+	} else if providerNeedsKey(providerOf(owner)) {
+		var err error
+		key, err = h.Keys.Decrypt(owner.EncryptedGroqKey, encryption.Binding(owner.TelegramID, models.SecretAPIKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.chatProviderWithKey(owner, key)
+}
+
+// chatProviderWithKey membuat klien AI untuk provider owner dengan key mentah, misalnya
+// key baru yang belum disimpan.
+func (h *BotHandler) chatProviderWithKey(owner *models.User, key string) (api.ChatProvider, error) {
+	provider := providerOf(owner)
+	baseURL := owner.AIBaseURL
+	if provider == api.ProviderLocal {
+		baseURL = h.LocalAIURL
+	}
+	return api.NewChatProvider(provider, baseURL, key)
+}
+
+// validBaseURL hanya menerima endpoint https milik owner yang mengarah ke alamat publik.
+// Klien OpenAI memeriksa ulang alamatnya setiap kali terhubung (lihat api.CheckPublicHost).
+func validBaseURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return false
+	}
+	return api.CheckPublicHost(u.Hostname()) == nil
+}
+
+func (h *BotHandler) getProviderMarkup(lang string) map[string]interface{} {
+	rows := [][]map[string]interface{}{
+		{{"text": "Groq", "callback_data": "set_provider_" + api.ProviderGroq}},
+		{{"text": h.I18n.Get(lang, "provider_openai"), "callback_data": "set_provider_" + api.ProviderOpenAI}},
+	}
+	if h.LocalAIURL != "" {
+		rows = append(rows, []map[string]interface{}{{"text": h.I18n.Get(lang, "provider_local"), "callback_data": "set_provider_" + api.ProviderLocal}})
+	}
+	rows = append(rows, []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}})
+	return map[string]interface{}{"inline_keyboard": rows}
+}
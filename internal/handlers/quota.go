@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// usesSharedKey bernilai true jika owner belum punya key sendiri dan operator menyediakan
+// key Groq platform (GROQ_API_KEY). Key platform hanya berlaku untuk provider Groq.
+func (h *BotHandler) usesSharedKey(owner *models.User) bool {
+	return h.SharedKey != "" && owner.EncryptedGroqKey == "" && providerOf(owner) == api.ProviderGroq
+}
+
+// hasUsableKey bernilai true jika owner bisa memanggil provider: dengan key sendiri, key
+// platform, atau provider yang tidak butuh key.
+func (h *BotHandler) hasUsableKey(owner *models.User) bool {
+	return !providerNeedsKey(providerOf(owner)) || owner.EncryptedGroqKey != "" || h.usesSharedKey(owner)
+}
+
+// quotaDayStart adalah awal hari kuota (UTC).
+func quotaDayStart(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// sharedUsedToday menghitung balasan hari ini yang memakai key platform.
+func (h *BotHandler) sharedUsedToday(ownerID int64) (int, error) {
+	totals, err := h.sumUsage(ownerID, quotaDayStart(time.Now()))
+	return totals.SharedReplies, err
+}
+
+// reserveSharedQuota memesan satu balasan dari kuota harian owner. Balasan yang sedang
+// diproses ikut dihitung supaya worker paralel tidak melewati kuota. Setiap pemesanan yang
+// berhasil harus diakhiri dengan releaseSharedQuota.
+func (h *BotHandler) reserveSharedQuota(ownerID int64) (bool, error) {
+	if h.SharedDailyQuota <= 0 {
+		// 0 berarti tanpa batas
+		h.quotaMu.Lock()
+		h.sharedInFlight[ownerID]++
+		h.quotaMu.Unlock()
+		return true, nil
+	}
+
+	// Hitung ulang dan pemesanan untuk owner yang sama harus berurutan, tapi owner lain tidak
+	// perlu menunggu query database owner ini
+	ownerMu := h.ownerQuotaLock(ownerID)
+	ownerMu.Lock()
+	defer ownerMu.Unlock()
+	used, err := h.sharedUsedToday(ownerID)
+	if err != nil {
+		return false, err
+	}
+
+	h.quotaMu.Lock()
+	defer h.quotaMu.Unlock()
+	if used+h.sharedInFlight[ownerID] >= h.SharedDailyQuota {
+		return false, nil
+	}
+	h.sharedInFlight[ownerID]++
+	return true, nil
+}
+
+// ownerQuotaLock mengembalikan mutex pemesanan kuota milik satu owner.
+func (h *BotHandler) ownerQuotaLock(ownerID int64) *sync.Mutex {
+	h.quotaMu.Lock()
+	defer h.quotaMu.Unlock()
+	mu, ok := h.quotaLocks[ownerID]
+	if !ok {
+		mu = &sync.Mutex{}
+		h.quotaLocks[ownerID] = mu
+	}
+	return mu
+}
+
+func (h *BotHandler) releaseSharedQuota(ownerID int64) {
+	h.quotaMu.Lock()
+	defer h.quotaMu.Unlock()
+	if h.sharedInFlight[ownerID] <= 1 {
+		delete(h.sharedInFlight, ownerID)
+		return
+	}
+	h.sharedInFlight[ownerID]--
+}
+
+// notifyQuotaExhausted memberi tahu owner sekali per hari bahwa kuota key platform habis.
+func (h *BotHandler) notifyQuotaExhausted(owner *models.User) {
+	day := quotaDayStart(time.Now()).Format("2006-01-02")
+	h.quotaMu.Lock()
+	if h.quotaNotice[owner.TelegramID] == day {
+		h.quotaMu.Unlock()
+		return
+	}
+	h.quotaNotice[owner.TelegramID] = day
+	h.quotaMu.Unlock()
+
+	log.Printf("System: owner %d used up the shared key quota for %s", owner.TelegramID, day)
+	h.notifyOwner(owner, h.I18n.Get(owner.Language, "shared_quota_exhausted"))
+}
+
+// recordUsage mencatat balasan AI beserta token dan biayanya; kegagalan hanya dicatat di log
+// karena balasan sudah terkirim.
+func (h *BotHandler) recordUsage(owner *models.User, customerID int64, shared bool, usage api.TokenUsage) {
+	rec := models.UsageRecord{
+		OwnerID:          owner.TelegramID,
+		CustomerID:       customerID,
+		Model:            owner.AIModel,
+		SharedKey:        shared,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             h.costOf(owner.AIModel, usage),
+		CreatedAt:        time.Now().UTC(),
+	}
+	err := database.Retry(dbRetryAttempts, func() error { return h.DB.RecordUsage(rec) })
+	if err != nil {
+		log.Printf("Storage Error: owner %d: record usage: %v", owner.TelegramID, err)
+	}
+}
+
+// sharedKeyText menampilkan key platform dan sisa kuota hari ini di dashboard.
+func (h *BotHandler) sharedKeyText(user *models.User) string {
+	lang := user.Language
+	if h.SharedDailyQuota <= 0 {
+		return h.I18n.Get(lang, "key_shared_unlimited")
+	}
+	used, err := h.sharedUsedToday(user.TelegramID)
+	if err != nil {
+		log.Printf("Storage Error: owner %d: %v", user.TelegramID, err)
+		return h.I18n.Get(lang, "key_shared")
+	}
+	left := h.SharedDailyQuota - used
+	if left < 0 {
+		left = 0
+	}
+	return h.I18n.Get(lang, "key_shared") + " · " + fmt.Sprintf(h.I18n.Get(lang, "key_shared_left"), left, h.SharedDailyQuota)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/i18n"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+func TestReserveSharedQuotaCountsTodayAndInFlight(t *testing.T) {
+	db := database.NewMemoryStore()
+	h := NewBotHandler(db, &fakeTelegram{}, i18n.NewBundle(), nil)
+	h.SharedDailyQuota = 3
+
+	now := time.Now().UTC()
+	for _, rec := range []models.UsageRecord{
+		{OwnerID: 1, SharedKey: true, CreatedAt: now},
+		{OwnerID: 1, SharedKey: true, CreatedAt: now},
+		{OwnerID: 1, SharedKey: false, CreatedAt: now},                     // key milik owner sendiri
+		{OwnerID: 1, SharedKey: true, CreatedAt: now.Add(-48 * time.Hour)}, // kuota kemarin
+		{OwnerID: 2, SharedKey: true, CreatedAt: now},
+	} {
+		if err := db.RecordUsage(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := h.reserveSharedQuota(1); err != nil || !ok {
+		t.Fatalf("first reserve = %v, %v; want true", ok, err)
+	}
+	// 2 tercatat hari ini + 1 yang sedang diproses sudah memenuhi kuota 3
+	if ok, err := h.reserveSharedQuota(1); err != nil || ok {
+		t.Fatalf("second reserve = %v, %v; want false", ok, err)
+	}
+	h.releaseSharedQuota(1)
+	if ok, err := h.reserveSharedQuota(1); err != nil || !ok {
+		t.Fatalf("reserve after release = %v, %v; want true", ok, err)
+	}
+}
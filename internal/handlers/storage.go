@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"html"
+	"log"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// dbRetryAttempts adalah jumlah percobaan untuk operasi database yang gagal sementara.
+const dbRetryAttempts = 3
+
+// errorNoticeInterval membatasi pemberitahuan error ke owner agar chat pribadinya tidak dibanjiri.
+const errorNoticeInterval = 15 * time.Minute
+
+// loadUser mengambil user atau membuatnya dengan pengaturan default jika belum ada.
+func (h *BotHandler) loadUser(telegramID int64, isPremium bool) (*models.User, error) {
+	var user *models.User
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		user, err = h.DB.GetUser(telegramID)
+		return err
+	})
+	if err == nil {
+		if migrateLegacyWait(user) {
+			h.saveUser(user)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+
+	newUser := models.User{TelegramID: telegramID, Language: "en", AIModel: "openai/gpt-oss-120b", SystemPrompt: "You are a professional assistant.", IsPremium: isPremium}
+	if err := h.saveUser(&newUser); err != nil && !errors.Is(err, database.ErrConflict) {
+		return nil, err
+	}
+	// Conflict berarti user baru saja dibuat oleh update lain; cukup baca ulang
+	return h.DB.GetUser(telegramID)
+}
+
+// saveUser menyimpan user dengan retry untuk error sementara.
+func (h *BotHandler) saveUser(user *models.User) error {
+	err := database.Retry(dbRetryAttempts, func() error {
+		return h.DB.UpsertUser(*user)
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+	}
+	return err
+}
+
+// storageErrorText memilih pesan yang sesuai dengan jenis error penyimpanan.
+func (h *BotHandler) storageErrorText(lang string, err error) string {
+	switch {
+	case errors.Is(err, database.ErrAuth):
+		return h.I18n.Get(lang, "db_error_auth")
+	case errors.Is(err, database.ErrInvalid):
+		return h.I18n.Get(lang, "db_error_invalid")
+	}
+	return h.I18n.Get(lang, "db_error_transient")
+}
+
+// notifyOwnerStorageError memberi tahu owner bahwa balasan otomatis dilewati karena database gagal.
+func (h *BotHandler) notifyOwnerStorageError(owner *models.User, err error) {
+	log.Printf("Storage Error: owner %d: %v", owner.TelegramID, err)
+
+	h.noticeMu.Lock()
+	last := h.lastErrorNotice[owner.TelegramID]
+	if time.Since(last) < errorNoticeInterval {
+		h.noticeMu.Unlock()
+		return
+	}
+	h.lastErrorNotice[owner.TelegramID] = time.Now()
+	h.noticeMu.Unlock()
+
+	lang := owner.Language
+	text := h.I18n.Get(lang, "db_reply_skipped") + "\n\n" + h.storageErrorText(lang, err) + "\n<code>" + html.EscapeString(err.Error()) + "</code>"
+	h.notifyOwner(owner, text)
+}
+
+// notifyOwner mengirim pemberitahuan ke chat pribadi owner. Kegagalan (mis. owner memblokir
+// bot) hanya dicatat karena pemberitahuan bersifat best-effort.
+func (h *BotHandler) notifyOwner(owner *models.User, text string) {
+	if _, err := h.TG.SendMessage(owner.TelegramID, text, "", nil); err != nil {
+		log.Printf("Warning: Failed to notify owner %d: %v", owner.TelegramID, err)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/format"
+	"tg-business-bot/internal/models"
+	"time"
+	"unicode/utf8"
+)
+
+// typingInterval mengulang status "typing" sebelum hilang (Telegram menampilkannya ~5 detik).
+const typingInterval = 4 * time.Second
+
+// streamFirstChunkChars adalah panjang minimal teks sebelum potongan pertama dikirim, supaya
+// pelanggan tidak menerima pesan berisi satu-dua kata.
+const streamFirstChunkChars = 40
+
+// previewLimit menjaga pratinjau di bawah batas 4096 karakter pesan Telegram.
+const previewLimit = 4000
+
+// previewText mengubah balasan HTML yang belum selesai menjadi teks biasa yang aman dikirim
+// tanpa parse mode: tag (termasuk tag yang terpotong di akhir) dibuang dan entity dibuka.
+func previewText(raw string) string {
+	if idx := strings.LastIndexByte(raw, '<'); idx >= 0 && !strings.Contains(raw[idx:], ">") {
+		raw = raw[:idx]
+	}
+	text := format.PlainText(raw)
+	if utf8.RuneCountInString(text) > previewLimit {
+		text = string([]rune(text)[:previewLimit])
+	}
+	return text
+}
+
+// replyStream menampung teks yang sudah diterima dari provider dan pesan pratinjau yang
+// sudah ditampilkan ke pelanggan.
+type replyStream struct {
+	mu     sync.Mutex
+	text   strings.Builder
+	sentID int64
+	shown  string
+	failed bool
+}
+
+func (s *replyStream) append(delta string) {
+	s.mu.Lock()
+	s.text.WriteString(delta)
+	s.mu.Unlock()
+}
+
+// flush mengirim atau mengedit pesan pratinjau dengan teks terbaru. Jika Telegram menolak,
+// pratinjau dihentikan dan balasan lengkap dikirim seperti biasa di akhir.
+func (h *BotHandler) flushPreview(s *replyStream, msg *api.Message) {
+	s.mu.Lock()
+	preview := previewText(s.text.String())
+	sentID, shown, failed := s.sentID, s.shown, s.failed
+	s.mu.Unlock()
+
+	if failed || preview == shown || (sentID == 0 && utf8.RuneCountInString(preview) < streamFirstChunkChars) {
+		return
+	}
+
+	var err error
+	if sentID == 0 {
+		sentID, err = h.TG.SendText(msg.Chat.ID, preview+" …", "", msg.BusinessConnectionID)
+	} else {
+		err = h.TG.EditText(msg.Chat.ID, sentID, preview+" …", "", msg.BusinessConnectionID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: streaming preview to chat %d stopped: %v", msg.Chat.ID, err)
+		s.failed = true
+		return
+	}
+	s.sentID, s.shown = sentID, preview
+}
+
+// generateReply meminta balasan AI sambil menampilkan "typing" di chat pelanggan lewat koneksi
+// bisnis. Jika provider mendukung streaming, potongan pertama dikirim sebagai pesan baru lalu
+// pesan itu diedit setiap StreamEditInterval. Mengembalikan ID pesan pratinjau (0 jika belum
+// ada yang terkirim) supaya deliverReply bisa mengganti isinya dengan balasan final.
+func (h *BotHandler) generateReply(provider api.ChatProvider, model string, history []models.ChatMessage, msg *api.Message) (*api.Completion, int64, error) {
+	stream := &replyStream{}
+	streamer, canStream := provider.(api.StreamingProvider)
+	canStream = canStream && h.StreamEditInterval > 0
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.TG.SendChatAction(msg.Chat.ID, "typing", msg.BusinessConnectionID)
+		typing := time.NewTicker(typingInterval)
+		defer typing.Stop()
+		var edits <-chan time.Time
+		if canStream {
+			ticker := time.NewTicker(h.StreamEditInterval)
+			defer ticker.Stop()
+			edits = ticker.C
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			case <-typing.C:
+				stream.mu.Lock()
+				waiting := stream.sentID == 0
+				stream.mu.Unlock()
+				if waiting {
+					h.TG.SendChatAction(msg.Chat.ID, "typing", msg.BusinessConnectionID)
+				}
+			case <-edits:
+				h.flushPreview(stream, msg)
+			}
+		}
+	}()
+
+	var completion *api.Completion
+	var err error
+	if canStream {
+		completion, err = streamer.StreamChatCompletion(model, history, stream.append)
+	} else {
+		completion, err = provider.GetChatCompletion(model, history)
+	}
+	close(stop)
+	wg.Wait()
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	return completion, stream.sentID, err
+}
+
+// discardPreview membuang pesan pratinjau yang tidak akan pernah berisi balasan final. Pratinjau
+// dihapus jika owner memberi hak hapus; jika tidak bisa, isinya diganti teks pengganti supaya
+// pelanggan tidak melihat balasan setengah jadi dengan " …" di akhir.
+func (h *BotHandler) discardPreview(owner *models.User, msg *api.Message, previewID int64) {
+	if owner.BusinessCanDeleteMessages {
+		err := h.TG.DeleteBusinessMessages(msg.BusinessConnectionID, previewID)
+		if err == nil {
+			return
+		}
+		log.Printf("Warning: cannot delete streaming preview in chat %d: %v", msg.Chat.ID, err)
+	}
+	if err := h.TG.EditText(msg.Chat.ID, previewID, h.I18n.Get(owner.Language, "reply_unavailable"), "", msg.BusinessConnectionID); err != nil {
+		log.Printf("Warning: cannot replace streaming preview in chat %d: %v", msg.Chat.ID, err)
+	}
+}
+
+// deliverReply menampilkan balasan final yang sudah melewati format.Sanitize. Balasan dipecah
+// per batas pesan Telegram; bagian pertama mengganti pesan pratinjau (jika ada) dan sisanya
+// dikirim sebagai pesan baru. Bagian yang ditolak Telegram dikirim ulang sebagai teks biasa.
+func (h *BotHandler) deliverReply(owner *models.User, msg *api.Message, text string, previewID int64) {
+	parts := format.Split(text, format.MessageLimit)
+	if len(parts) == 0 {
+		// Balasan kosong setelah Sanitize (mis. model hanya mengirim tag yang dibuang)
+		log.Printf("Warning: reply for chat %d is empty after formatting, nothing sent", msg.Chat.ID)
+		if previewID != 0 {
+			h.discardPreview(owner, msg, previewID)
+		}
+		return
+	}
+	for i, part := range parts {
+		editID := int64(0)
+		if i == 0 {
+			editID = previewID
+		}
+		err := h.sendReplyPart(msg, editID, part, "HTML")
+		if err == nil {
+			continue
+		}
+		log.Printf("Warning: reply part %d in chat %d rejected, falling back to plain text: %v", i+1, msg.Chat.ID, err)
+		for j, plain := range format.SplitPlain(format.PlainText(part), format.MessageLimit) {
+			if j > 0 {
+				editID = 0
+			}
+			if err := h.sendReplyPart(msg, editID, plain, ""); err != nil {
+				log.Printf("Warning: plain-text reply part %d in chat %d failed: %v", i+1, msg.Chat.ID, err)
+			}
+		}
+	}
+}
+
+// sendReplyPart mengedit pesan editID jika ada, atau mengirim pesan baru.
+func (h *BotHandler) sendReplyPart(msg *api.Message, editID int64, text, parseMode string) error {
+	if editID != 0 {
+		return h.TG.EditText(msg.Chat.ID, editID, text, parseMode, msg.BusinessConnectionID)
+	}
+	_, err := h.TG.SendText(msg.Chat.ID, text, parseMode, msg.BusinessConnectionID)
+	return err
+}
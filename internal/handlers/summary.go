@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// summaryPrompt meminta model memperbarui ringkasan berjalan dengan pesan-pesan baru.
+const summaryPrompt = `You maintain a running summary of a conversation between a business (assistant) and one customer.
+Update the existing summary with the new messages. Keep everything the business will need later: the customer's name, orders and their details, prices quoted, addresses, preferences, promises made and open questions.
+Drop small talk. Write plain text without HTML or Markdown, at most 200 words, in the language the customer uses.`
+
+// summaryBatchLimit membatasi jumlah pesan yang diringkas dalam satu panggilan model; sisanya
+// diringkas pada pembaruan berikutnya.
+const summaryBatchLimit = 60
+
+// summarizer mencegah ringkasan percakapan yang sama diperbarui dua kali bersamaan.
+type summarizer struct {
+	mu      sync.Mutex
+	running map[[2]int64]bool
+}
+
+func newSummarizer() *summarizer {
+	return &summarizer{running: make(map[[2]int64]bool)}
+}
+
+func (s *summarizer) start(key [2]int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[key] {
+		return false
+	}
+	s.running[key] = true
+	return true
+}
+
+func (s *summarizer) done(key [2]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, key)
+}
+
+// loadSummary mengembalikan ringkasan percakapan, atau ringkasan kosong jika belum ada.
+func (h *BotHandler) loadSummary(ownerID, customerID int64) (*models.ConversationSummary, error) {
+	var summary *models.ConversationSummary
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		summary, err = h.DB.GetSummary(ownerID, customerID)
+		return err
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return &models.ConversationSummary{OwnerID: ownerID, CustomerID: customerID}, nil
+	}
+	return summary, err
+}
+
+// recentHistory mengambil pesan yang belum masuk ringkasan (dibatasi historyFetchLimit)
+// beserta ringkasannya, siap dipangkas oleh fitHistory.
+func (h *BotHandler) recentHistory(ownerID, customerID int64) ([]models.ChatMessage, *models.ConversationSummary, error) {
+	summary, err := h.loadSummary(ownerID, customerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit := historyFetchLimit
+	if summary.CoveredCount > 0 {
+		var total int
+		err = database.Retry(dbRetryAttempts, func() error {
+			var err error
+			total, err = h.DB.CountMessages(ownerID, customerID)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if uncovered := total - summary.CoveredCount; uncovered < limit {
+			limit = uncovered
+		}
+		if limit < 1 {
+			limit = 1
+		}
+	}
+
+	var history []models.ChatMessage
+	err = database.Retry(dbRetryAttempts, func() error {
+		var err error
+		history, err = h.DB.GetChatHistory(ownerID, customerID, limit)
+		return err
+	})
+	return asProviderRoles(history), summary, err
+}
+
+// refreshSummaryAsync memperbarui ringkasan di latar belakang jika pesan yang belum
+// diringkas sudah melewati SummaryThreshold. SummaryKeepRecent pesan terbaru selalu
+// dibiarkan utuh sebagai konteks langsung.
+func (h *BotHandler) refreshSummaryAsync(owner *models.User, customerID int64) {
+	if h.SummaryThreshold <= 0 {
+		return
+	}
+	key := [2]int64{owner.TelegramID, customerID}
+	if !h.summaries.start(key) {
+		return
+	}
+	ownerCopy := *owner
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		defer h.summaries.done(key)
+		if err := h.refreshSummary(&ownerCopy, customerID); err != nil {
+			log.Printf("Summary Error: owner %d customer %d: %v", owner.TelegramID, customerID, err)
+		}
+	}()
+}
+
+func (h *BotHandler) refreshSummary(owner *models.User, customerID int64) error {
+	summary, err := h.loadSummary(owner.TelegramID, customerID)
+	if err != nil {
+		return err
+	}
+	total, err := h.DB.CountMessages(owner.TelegramID, customerID)
+	if err != nil {
+		return err
+	}
+	if total < summary.CoveredCount {
+		// History dihapus sebagian di luar bot; mulai ulang dari awal
+		summary.Summary, summary.CoveredCount = "", 0
+	}
+	upTo := total - h.SummaryKeepRecent
+	if upTo-summary.CoveredCount < h.SummaryThreshold {
+		return nil
+	}
+
+	batch := upTo - summary.CoveredCount
+	if batch > summaryBatchLimit {
+		batch = summaryBatchLimit
+	}
+	msgs, err := h.DB.GetMessages(owner.TelegramID, customerID, summary.CoveredCount, batch)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if summary.Summary != "" {
+		b.WriteString("Current summary:\n" + summary.Summary + "\n\n")
+	}
+	b.WriteString("New messages:\n")
+	for _, m := range msgs {
+		speaker := "Customer"
+		if m.Role == "assistant" {
+			speaker = "Business"
+		} else if m.Role == roleOwner {
+			speaker = "Business owner"
+		}
+		b.WriteString(speaker + ": " + m.Content + "\n")
+	}
+
+	// Ringkasan memakai kuota dan batas belanja yang sama dengan balasan; jika sudah habis,
+	// ringkasan ditunda sampai pembaruan berikutnya
+	reached, err := h.reachedSpendCap(owner)
+	if err != nil || reached != "" {
+		return err
+	}
+	shared := h.usesSharedKey(owner)
+	if shared {
+		ok, err := h.reserveSharedQuota(owner.TelegramID)
+		if err != nil || !ok {
+			return err
+		}
+		defer h.releaseSharedQuota(owner.TelegramID)
+	}
+
+	provider, err := h.newChatProvider(owner)
+	if err != nil {
+		return err
+	}
+	completion, err := provider.GetChatCompletion(owner.AIModel, []models.ChatMessage{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: b.String()},
+	})
+	if err != nil {
+		return err
+	}
+	h.recordUsage(owner, customerID, shared, completion.Usage)
+
+	summary.Summary = strings.TrimSpace(completion.Content)
+	summary.CoveredCount += len(msgs)
+	summary.UpdatedAt = time.Now().UTC()
+	return database.Retry(dbRetryAttempts, func() error { return h.DB.SaveSummary(*summary) })
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// roleOwner menandai pesan yang diketik owner sendiri di chat pelanggan. Untuk provider AI
+// pesan ini dikirim sebagai "assistant" karena mewakili pihak bisnis.
+const roleOwner = "owner"
+
+// isOwnerMessage mengenali pesan bisnis yang diketik owner sendiri, bukan pelanggan. Pengirim
+// dicocokkan dengan UserChatID koneksi bisnis (chat pribadi owner dengan bot); baris lama yang
+// belum menyimpannya memakai TelegramID. Pesan yang dikirim bot atas nama owner
+// (sender_business_bot) bukan balasan manual.
+func isOwnerMessage(owner *models.User, msg *api.Message) bool {
+	if msg.From == nil || msg.SenderBusinessBot != nil {
+		return false
+	}
+	ownerChatID := owner.BusinessUserChatID
+	if ownerChatID == 0 {
+		ownerChatID = owner.TelegramID
+	}
+	return msg.From.ID == ownerChatID
+}
+
+// handleOwnerMessage menyimpan balasan manual owner sebagai giliran human agent lalu menjeda
+// AI untuk pelanggan tersebut selama TakeoverCooldown. Owner diberi tahu hanya saat jeda baru
+// dimulai, bukan setiap kali jeda diperpanjang.
+func (h *BotHandler) handleOwnerMessage(owner *models.User, msg *api.Message) {
+	customerID := msg.Chat.ID
+	if msg.Text != "" {
+		// Nama kosong tidak menimpa nama pelanggan di daftar (lihat addCustomer)
+		err := database.Retry(dbRetryAttempts, func() error {
+			return h.DB.SaveMessage(owner.TelegramID, customerID, "", roleOwner, msg.Text)
+		})
+		if err != nil {
+			log.Printf("Storage Error: owner message in chat %d: %v", customerID, err)
+		}
+	}
+	if h.TakeoverCooldown <= 0 {
+		return
+	}
+
+	until, err := h.chatPausedUntil(owner.TelegramID, customerID)
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		return
+	}
+	pause := models.ChatPause{OwnerID: owner.TelegramID, CustomerID: customerID, PausedUntil: time.Now().Add(h.TakeoverCooldown)}
+	err = database.Retry(dbRetryAttempts, func() error {
+		return h.DB.SaveChatPause(pause)
+	})
+	if err != nil {
+		log.Printf("Storage Error: pause chat %d: %v", customerID, err)
+		return
+	}
+	if !until.IsZero() {
+		return
+	}
+
+	log.Printf("System: owner %d took over chat %d, AI paused for %v", owner.TelegramID, customerID, h.TakeoverCooldown)
+	lang := owner.Language
+	markup := map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{{"text": h.I18n.Get(lang, "btn_resume_ai"), "callback_data": fmt.Sprintf("resume_%d", customerID)}},
+		},
+	}
+	text := fmt.Sprintf(h.I18n.Get(lang, "takeover_paused"), formatRemaining(h.TakeoverCooldown))
+	if _, err := h.TG.SendMessage(owner.TelegramID, text, "", markup); err != nil {
+		log.Printf("Warning: Failed to notify owner %d: %v", owner.TelegramID, err)
+	}
+}
+
+// chatPausedUntil mengembalikan akhir jeda AI untuk pelanggan, atau waktu nol jika tidak dijeda.
+// Jeda yang sudah lewat dihapus.
+func (h *BotHandler) chatPausedUntil(ownerID, customerID int64) (time.Time, error) {
+	var pause *models.ChatPause
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		pause, err = h.DB.GetChatPause(ownerID, customerID)
+		return err
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if time.Now().Before(pause.PausedUntil) {
+		return pause.PausedUntil, nil
+	}
+	if err := h.DB.DeleteChatPause(ownerID, customerID); err != nil {
+		log.Printf("Warning: Failed to delete expired pause of chat %d: %v", customerID, err)
+	}
+	return time.Time{}, nil
+}
+
+// asProviderRoles mengubah giliran owner menjadi "assistant" sebelum history dikirim ke AI.
+func asProviderRoles(history []models.ChatMessage) []models.ChatMessage {
+	for i := range history {
+		if history[i].Role == roleOwner {
+			history[i].Role = "assistant"
+		}
+	}
+	return history
+}
+
+// customerNames memetakan ID pelanggan ke nama tampilannya. Kegagalan database hanya
+// membuat nama tidak tersedia.
+func (h *BotHandler) customerNames(ownerID int64) map[int64]string {
+	names := make(map[int64]string)
+	customers, err := h.DB.GetBusinessCustomers(ownerID)
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		return names
+	}
+	for _, c := range customers {
+		var cid int64
+		if v, ok := c["customer_id"].(int64); ok {
+			cid = v
+		} else if v, ok := c["customer_id"].(float64); ok {
+			cid = int64(v)
+		}
+		names[cid], _ = c["customer_name"].(string)
+	}
+	return names
+}
+
+// formatRemaining menampilkan durasi jeda dalam jam dan menit, misalnya "1h05m" atau "25m".
+func formatRemaining(d time.Duration) string {
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	if minutes >= 60 {
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// showPausedChats menampilkan percakapan yang AI-nya sedang dijeda, dengan tombol untuk
+// melanjutkan AI per pelanggan atau sekaligus.
+func (h *BotHandler) showPausedChats(chatID, messageID int64, user *models.User, note string) {
+	lang := user.Language
+	back := []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}}
+
+	var pauses []models.ChatPause
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		pauses, err = h.DB.ListChatPauses(user.TelegramID, time.Now())
+		return err
+	})
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		h.TG.EditMessage(chatID, messageID, h.storageErrorText(lang, err), map[string]interface{}{
+			"inline_keyboard": [][]map[string]interface{}{back},
+		})
+		return
+	}
+
+	text := h.I18n.Get(lang, "paused_title")
+	if note != "" {
+		text = note + "\n\n" + text
+	}
+	if len(pauses) == 0 {
+		text += "\n\n" + h.I18n.Get(lang, "paused_none")
+		h.TG.EditMessage(chatID, messageID, text, map[string]interface{}{
+			"inline_keyboard": [][]map[string]interface{}{back},
+		})
+		return
+	}
+
+	names := h.customerNames(user.TelegramID)
+	var buttons [][]map[string]interface{}
+	for _, p := range pauses {
+		name := names[p.CustomerID]
+		if name == "" {
+			name = fmt.Sprintf("User %d", p.CustomerID)
+		}
+		label := fmt.Sprintf(h.I18n.Get(lang, "paused_line"), name, formatRemaining(time.Until(p.PausedUntil)))
+		buttons = append(buttons, []map[string]interface{}{{"text": label, "callback_data": fmt.Sprintf("resume_%d", p.CustomerID)}})
+	}
+	buttons = append(buttons, []map[string]interface{}{{"text": h.I18n.Get(lang, "btn_resume_all"), "callback_data": "resume_all"}})
+	buttons = append(buttons, back)
+	h.TG.EditMessage(chatID, messageID, text, map[string]interface{}{"inline_keyboard": buttons})
+}
+
+// resumeChat menghapus jeda AI dari callback "resume_<customerID>" atau "resume_all".
+func (h *BotHandler) resumeChat(chatID, messageID int64, user *models.User, data string) {
+	var targets []int64
+	if data == "resume_all" {
+		pauses, err := h.DB.ListChatPauses(user.TelegramID, time.Now())
+		if err != nil {
+			log.Printf("Storage Error: %v", err)
+			h.showPausedChats(chatID, messageID, user, h.storageErrorText(user.Language, err))
+			return
+		}
+		for _, p := range pauses {
+			targets = append(targets, p.CustomerID)
+		}
+	} else if cid, err := strconv.ParseInt(data[len("resume_"):], 10, 64); err == nil {
+		targets = append(targets, cid)
+	}
+
+	for _, cid := range targets {
+		err := database.Retry(dbRetryAttempts, func() error {
+			return h.DB.DeleteChatPause(user.TelegramID, cid)
+		})
+		if err != nil {
+			log.Printf("Storage Error: %v", err)
+			h.showPausedChats(chatID, messageID, user, h.storageErrorText(user.Language, err))
+			return
+		}
+	}
+	h.showPausedChats(chatID, messageID, user, h.I18n.Get(user.Language, "resume_done"))
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// maxPromptBytes membatasi hasil render prompt, supaya template seperti {{range}} yang besar
+// tidak menghabiskan memori.
+const maxPromptBytes = 32 * 1024
+
+// maxPromptVars membatasi jumlah variabel buatan owner.
+const maxPromptVars = 30
+
+// varNamePattern adalah nama variabel yang valid sebagai fungsi template: {{promo_code}}.
+var varNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// builtinPlaceholders adalah placeholder bawaan yang tidak boleh dipakai sebagai nama variabel.
+var builtinPlaceholders = map[string]bool{
+	"customer_name": true, "customer_username": true, "customer_id": true,
+	"owner_name": true, "owner_first_name": true, "owner_username": true,
+	"current_time": true, "current_date": true, "current_day": true,
+	"business_location": true, "is_open": true, "next_opening": true,
+	"is_open_now": true, "is_weekend": true, "is_returning_customer": true, "hour": true,
+	"var": true,
+}
+
+// errPromptTooLong dikembalikan jika hasil render melewati maxPromptBytes.
+var errPromptTooLong = errors.New("rendered prompt is too long")
+
+// limitedBuffer menolak tulisan setelah maxPromptBytes.
+type limitedBuffer struct {
+	strings.Builder
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxPromptBytes {
+		return 0, errPromptTooLong
+	}
+	return b.Builder.Write(p)
+}
+
+// syncProfile menyalin nama dan username owner dari profil Telegram. Mengembalikan true jika
+// ada yang berubah dan perlu disimpan.
+func syncProfile(user *models.User, from *api.User) bool {
+	if from == nil || from.ID != user.TelegramID {
+		return false
+	}
+	changed := user.FirstName != from.FirstName || user.LastName != from.LastName || user.Username != from.Username
+	user.FirstName, user.LastName, user.Username = from.FirstName, from.LastName, from.Username
+	return changed
+}
+
+// ownerName mengembalikan nama tampilan owner dari profil Telegram.
+func ownerName(owner *models.User) string {
+	name := strings.TrimSpace(owner.FirstName + " " + owner.LastName)
+	if name == "" && owner.Username != "" {
+		name = "@" + owner.Username
+	}
+	if name == "" {
+		name = "Owner"
+	}
+	return name
+}
+
+// placeholderValues mengembalikan nilai teks placeholder bawaan untuk satu pesan.
+func (h *BotHandler) placeholderValues(owner *models.User, customer *api.User, now time.Time) map[string]string {
+	isOpen, nextOpening := h.openStatus(owner, now)
+
+	// Data Pelanggan
+	customerName := customer.FirstName
+	if customer.LastName != "" {
+		customerName += " " + customer.LastName
+	}
+	customerUsername := "@" + customer.Username
+	if customer.Username == "" {
+		customerUsername = "n/a"
+	}
+	ownerUsername := "@" + owner.Username
+	if owner.Username == "" {
+		ownerUsername = "n/a"
+	}
+
+	return map[string]string{
+		"customer_name":     customerName,
+		"customer_username": customerUsername,
+		"customer_id":       fmt.Sprintf("%d", customer.ID),
+		"owner_name":        ownerName(owner),
+		"owner_first_name":  owner.FirstName,
+		"owner_username":    ownerUsername,
+		"current_time":      now.Format("15:04"),
+		"current_date":      h.localDate(owner.Language, now),
+		"current_day":       h.localDay(owner.Language, now),
+		"business_location": owner.BusinessLocation,
+		"is_open":           isOpen,
+		"next_opening":      nextOpening,
+	}
+}
+
+// promptFuncs menyusun fungsi template untuk prompt: placeholder bawaan, variabel owner
+// ({{promo_code}} atau {{var "promo_code"}}) dan kondisi untuk {{if}}. returning dipanggil
+// hanya jika template memakai {{is_returning_customer}}.
+func (h *BotHandler) promptFuncs(owner *models.User, customer *api.User, returning func() bool) template.FuncMap {
+	// Semua placeholder waktu memakai zona waktu dan bahasa owner, bukan zona server
+	now := time.Now().In(ownerLocation(owner))
+	values := h.placeholderValues(owner, customer, now)
+
+	funcs := template.FuncMap{}
+	for name, v := range owner.PromptVars {
+		if !builtinPlaceholders[name] {
+			v := v
+			funcs[name] = func() string { return v }
+		}
+	}
+	for name, v := range values {
+		v := v
+		funcs[name] = func() string { return v }
+	}
+	var once sync.Once
+	var isReturning bool
+	funcs["var"] = func(name string) string { return owner.PromptVars[name] }
+	funcs["is_open_now"] = func() bool { return values["is_open"] == "open" }
+	funcs["is_weekend"] = func() bool { return now.Weekday() == time.Saturday || now.Weekday() == time.Sunday }
+	funcs["is_returning_customer"] = func() bool {
+		once.Do(func() { isReturning = returning() })
+		return isReturning
+	}
+	funcs["hour"] = func() int { return now.Hour() }
+	return funcs
+}
+
+// renderPrompt menjalankan template prompt dengan fungsi yang diberikan.
+func renderPrompt(text string, funcs template.FuncMap) (string, error) {
+	tmpl, err := template.New("prompt").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out limitedBuffer
+	if err := tmpl.Execute(&out, nil); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// processPlaceholders merender prompt owner untuk pesan pelanggan. Prompt yang gagal dirender
+// (mis. disimpan sebelum template divalidasi) jatuh kembali ke penggantian teks biasa.
+func (h *BotHandler) processPlaceholders(prompt string, owner *models.User, msg *api.Message) string {
+	returning := func() bool {
+		var count int
+		err := database.Retry(dbRetryAttempts, func() error {
+			var err error
+			count, err = h.DB.CountMessages(owner.TelegramID, msg.Chat.ID)
+			return err
+		})
+		if err != nil {
+			log.Printf("Storage Error: %v", err)
+			return false
+		}
+		// Pesan yang sedang diproses sudah tersimpan, jadi pelanggan lama punya lebih dari satu
+		return count > 1
+	}
+	funcs := h.promptFuncs(owner, msg.From, returning)
+	rendered, err := renderPrompt(prompt, funcs)
+	if err == nil {
+		return rendered
+	}
+
+	log.Printf("Warning: prompt template of owner %d failed, using plain placeholders: %v", owner.TelegramID, err)
+	var pairs []string
+	for name, fn := range funcs {
+		if f, ok := fn.(func() string); ok {
+			pairs = append(pairs, "{{"+name+"}}", f())
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(prompt)
+}
+
+// sampleCustomer adalah pelanggan contoh untuk validasi dan pratinjau prompt.
+var sampleCustomer = &api.User{ID: 123456789, FirstName: "Alex", LastName: "Sample", Username: "alex_sample"}
+
+// validatePrompt memastikan prompt bisa dirender, untuk menolak input yang salah saat disimpan.
+func (h *BotHandler) validatePrompt(owner *models.User, prompt string) error {
+	_, err := renderPrompt(prompt, h.promptFuncs(owner, sampleCustomer, func() bool { return true }))
+	return err
+}
+
+// showPromptPreview menampilkan prompt bisnis (dan prompt di luar jam buka jika dipakai) yang
+// sudah dirender untuk pelanggan contoh, sebagai pelanggan baru dan pelanggan lama.
+func (h *BotHandler) showPromptPreview(chatID int64, user *models.User) {
+	lang := user.Language
+	var b strings.Builder
+	b.WriteString(h.I18n.Get(lang, "preview_title"))
+
+	prompts := []struct{ key, text string }{{"preview_prompt", user.SystemPrompt}}
+	if user.AwayMode == awayModePrompt && user.AwayPrompt != "" {
+		prompts = append(prompts, struct{ key, text string }{"preview_away_prompt", user.AwayPrompt})
+	}
+	for _, p := range prompts {
+		newText, err := renderPrompt(p.text, h.promptFuncs(user, sampleCustomer, func() bool { return false }))
+		if err != nil {
+			b.WriteString("\n\n" + h.I18n.Get(lang, p.key) + "\n" + fmt.Sprintf(h.I18n.Get(lang, "template_invalid"), html.EscapeString(err.Error())))
+			continue
+		}
+		returningText, _ := renderPrompt(p.text, h.promptFuncs(user, sampleCustomer, func() bool { return true }))
+		if returningText == newText {
+			b.WriteString("\n\n" + h.I18n.Get(lang, p.key) + "\n<pre>" + html.EscapeString(newText) + "</pre>")
+			continue
+		}
+		b.WriteString("\n\n" + h.I18n.Get(lang, p.key) + " · " + h.I18n.Get(lang, "preview_new_customer") + "\n<pre>" + html.EscapeString(newText) + "</pre>")
+		b.WriteString("\n\n" + h.I18n.Get(lang, p.key) + " · " + h.I18n.Get(lang, "preview_returning_customer") + "\n<pre>" + html.EscapeString(returningText) + "</pre>")
+	}
+	markup := map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{{"text": h.I18n.Get(lang, "btn_dashboard"), "callback_data": "back_main"}},
+		},
+	}
+	// Pratinjau panjang dipotong supaya tetap muat dalam satu pesan
+	text := b.String()
+	if len([]rune(text)) > previewLimit {
+		text = h.I18n.Get(lang, "preview_title") + "\n\n" + h.I18n.Get(lang, "preview_too_long")
+	}
+	if _, err := h.TG.SendMessage(chatID, text, "", markup); err != nil {
+		log.Printf("Warning: Failed to send prompt preview to %d: %v", chatID, err)
+	}
+}
+
+// showPromptVars menampilkan variabel prompt buatan owner.
+func (h *BotHandler) showPromptVars(chatID, messageID int64, user *models.User, note string) {
+	lang := user.Language
+	var names []string
+	for name := range user.PromptVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := h.I18n.Get(lang, "vars_none")
+	if len(names) > 0 {
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, "{{%s}} = %s\n", name, user.PromptVars[name])
+		}
+		list = "<pre>" + html.EscapeString(strings.TrimSpace(b.String())) + "</pre>"
+	}
+	text := fmt.Sprintf(h.I18n.Get(lang, "vars_title"), list)
+	if note != "" {
+		text = note + "\n\n" + text
+	}
+	markup := map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{
+				{"text": h.I18n.Get(lang, "btn_edit_vars"), "callback_data": "vars_edit"},
+				{"text": h.I18n.Get(lang, "btn_preview"), "callback_data": "menu_preview"},
+			},
+			{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}},
+		},
+	}
+	h.TG.EditMessage(chatID, messageID, text, markup)
+}
+
+// applyPromptVars mengubah variabel dari input "nama = nilai" per baris. "nama =" menghapus
+// variabel dan "-" menghapus semuanya. Mengembalikan alasan (sudah di-escape) jika tidak valid.
+func applyPromptVars(user *models.User, input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	vars := make(map[string]string)
+	if input != clearInput {
+		for name, v := range user.PromptVars {
+			vars[name] = v
+		}
+		for _, line := range strings.Split(input, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(line, "=")
+			name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "{}"))
+			if !ok || !varNamePattern.MatchString(name) {
+				return html.EscapeString(line), false
+			}
+			if builtinPlaceholders[name] {
+				return html.EscapeString(name), false
+			}
+			if value = strings.TrimSpace(value); value == "" {
+				delete(vars, name)
+			} else {
+				vars[name] = value
+			}
+		}
+		if len(vars) > maxPromptVars {
+			return fmt.Sprintf("max %d", maxPromptVars), false
+		}
+	}
+	user.PromptVars = vars
+	return "", true
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/i18n"
+	"tg-business-bot/internal/models"
+)
+
+func TestApplyPromptVars(t *testing.T) {
+	many := make([]string, maxPromptVars+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("v%d = x", i)
+	}
+
+	tests := []struct {
+		name       string
+		existing   map[string]string
+		input      string
+		want       map[string]string
+		wantReason string
+		wantOK     bool
+	}{
+		{"add", nil, "promo_code = SAVE10", map[string]string{"promo_code": "SAVE10"}, "", true},
+		{"braces and case", nil, "{{Promo_Code}} = SAVE10", map[string]string{"promo_code": "SAVE10"}, "", true},
+		{"value may contain =", nil, "link = a=b", map[string]string{"link": "a=b"}, "", true},
+		{"several lines keep the rest", map[string]string{"a1": "x"}, "b1 = y\n\nc1 = z", map[string]string{"a1": "x", "b1": "y", "c1": "z"}, "", true},
+		{"empty value deletes", map[string]string{"a1": "x", "b1": "y"}, "a1 =", map[string]string{"b1": "y"}, "", true},
+		{"dash clears everything", map[string]string{"a1": "x"}, " - ", map[string]string{}, "", true},
+		{"missing =", nil, "promo_code", nil, "promo_code", false},
+		{"invalid name", nil, "1abc = x", nil, "1abc = x", false},
+		{"reason is escaped", nil, "<b> = x", nil, "&lt;b&gt; = x", false},
+		{"builtin name", nil, "customer_name = x", nil, "customer_name", false},
+		{"too many", nil, strings.Join(many, "\n"), nil, fmt.Sprintf("max %d", maxPromptVars), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{PromptVars: tt.existing}
+			reason, ok := applyPromptVars(user, tt.input)
+			if ok != tt.wantOK || reason != tt.wantReason {
+				t.Fatalf("applyPromptVars(%q) = %q, %v, want %q, %v", tt.input, reason, ok, tt.wantReason, tt.wantOK)
+			}
+			if !ok {
+				if !reflect.DeepEqual(user.PromptVars, tt.existing) {
+					t.Errorf("rejected input changed the variables to %v", user.PromptVars)
+				}
+				return
+			}
+			if !reflect.DeepEqual(user.PromptVars, tt.want) {
+				t.Errorf("PromptVars = %v, want %v", user.PromptVars, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPrompt(t *testing.T) {
+	funcs := template.FuncMap{
+		"name": func() string { return "Alex" },
+		"big":  func() string { return strings.Repeat("x", maxPromptBytes+1) },
+		"yes":  func() bool { return true },
+	}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "Hello", "Hello", false},
+		{"placeholder", "Hello {{name}}", "Hello Alex", false},
+		{"condition", "{{if yes}}a{{else}}b{{end}}", "a", false},
+		{"unknown function", "{{nope}}", "", true},
+		{"syntax error", "{{if yes}}", "", true},
+		{"too long", "{{big}}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderPrompt(tt.text, funcs)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("renderPrompt(%q) = %q, %v", tt.text, got, err)
+			}
+		})
+	}
+	if _, err := renderPrompt("{{big}}", funcs); !errors.Is(err, errPromptTooLong) {
+		t.Errorf("renderPrompt of a huge prompt = %v, want errPromptTooLong", err)
+	}
+}
+
+func newTemplateTestHandler(t *testing.T) *BotHandler {
+	t.Helper()
+	bundle := i18n.NewBundle()
+	if err := bundle.LoadLocale("en", "../../locales/en.json"); err != nil {
+		t.Fatal(err)
+	}
+	return &BotHandler{I18n: bundle, DB: database.NewMemoryStore()}
+}
+
+func TestProcessPlaceholders(t *testing.T) {
+	h := newTemplateTestHandler(t)
+	owner := &models.User{
+		TelegramID: 1, FirstName: "Budi", Language: "en",
+		PromptVars: map[string]string{"promo_code": "SAVE10", "customer_name": "ignored"},
+	}
+	msg := &api.Message{Chat: &api.Chat{ID: 42}, From: &api.User{ID: 42, FirstName: "Alex", LastName: "Sample"}}
+
+	tests := []struct {
+		name   string
+		prompt string
+		want   string
+	}{
+		{"owner variable", "Code {{promo_code}}", "Code SAVE10"},
+		{"var function", `Code {{var "promo_code"}}`, "Code SAVE10"},
+		{"builtin wins over a variable", "Hi {{customer_name}}", "Hi Alex Sample"},
+		{"owner name", "I am {{owner_name}}", "I am Budi"},
+		{"no business hours means open", "{{if is_open_now}}open{{end}} {{next_opening}}", "open now"},
+		{"new customer", "{{if is_returning_customer}}again{{else}}new{{end}}", "new"},
+		{"fallback on syntax error", "Hi {{customer_name}}, code {{promo_code}} {{if}}", "Hi Alex Sample, code SAVE10 {{if}}"},
+		{"fallback on unknown placeholder", "Hi {{customer_name}} {{unknown}}", "Hi Alex Sample {{unknown}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.processPlaceholders(tt.prompt, owner, msg); got != tt.want {
+				t.Errorf("processPlaceholders(%q) = %q, want %q", tt.prompt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessPlaceholdersReturningCustomer(t *testing.T) {
+	h := newTemplateTestHandler(t)
+	owner := &models.User{TelegramID: 1, Language: "en"}
+	msg := &api.Message{Chat: &api.Chat{ID: 42}, From: &api.User{ID: 42, FirstName: "Alex"}}
+	prompt := "{{if is_returning_customer}}again{{else}}new{{end}}"
+
+	// Pesan yang sedang diproses sudah tersimpan sebelum prompt dirender
+	for _, text := range []string{"earlier", "now"} {
+		if err := h.DB.SaveMessage(owner.TelegramID, msg.Chat.ID, "Alex", "user", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := h.processPlaceholders(prompt, owner, msg); got != "again" {
+		t.Errorf("processPlaceholders = %q, want %q", got, "again")
+	}
+}
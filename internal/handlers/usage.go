@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+// ModelPrice adalah harga per 1 juta token dalam USD.
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// ParsePricing membaca MODEL_PRICING dengan format "model=input/output,..." (USD per 1 juta
+// token), misalnya "llama-3.3-70b-versatile=0.59/0.79,*=0.5/1". "*" berlaku untuk model lain.
+func ParsePricing(spec string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("price %q must have the form model=input/output", entry)
+		}
+		rates := strings.SplitN(parts[1], "/", 2)
+		if len(rates) != 2 {
+			return nil, fmt.Errorf("price %q must have the form model=input/output", entry)
+		}
+		input, err1 := strconv.ParseFloat(strings.TrimSpace(rates[0]), 64)
+		output, err2 := strconv.ParseFloat(strings.TrimSpace(rates[1]), 64)
+		if err1 != nil || err2 != nil || input < 0 || output < 0 {
+			return nil, fmt.Errorf("price %q has an invalid rate", entry)
+		}
+		prices[strings.TrimSpace(parts[0])] = ModelPrice{Input: input, Output: output}
+	}
+	return prices, nil
+}
+
+// priceOf mengembalikan harga model, atau harga "*" jika model tidak terdaftar.
+func (h *BotHandler) priceOf(model string) (ModelPrice, bool) {
+	if price, ok := h.Prices[model]; ok {
+		return price, true
+	}
+	price, ok := h.Prices["*"]
+	return price, ok
+}
+
+// costOf menghitung biaya satu balasan. Biaya disimpan bersama catatan pemakaian supaya
+// perubahan harga tidak mengubah laporan lama.
+func (h *BotHandler) costOf(model string, usage api.TokenUsage) float64 {
+	price, _ := h.priceOf(model)
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+}
+
+// monthStart adalah awal bulan kalender (UTC), periode batas belanja bulanan.
+func monthStart(now time.Time) time.Time {
+	y, m, _ := now.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// listMonthUsage mengambil semua pemakaian owner sejak awal bulan ini untuk laporan rinci.
+func (h *BotHandler) listMonthUsage(ownerID int64) ([]models.UsageRecord, error) {
+	var records []models.UsageRecord
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		records, err = h.DB.ListUsage(ownerID, monthStart(time.Now()))
+		return err
+	})
+	return records, err
+}
+
+// Batas belanja yang bisa dicapai owner.
+const (
+	capDaily   = "daily"
+	capMonthly = "monthly"
+)
+
+// sumUsage menjumlahkan pemakaian owner sejak since di storage.
+func (h *BotHandler) sumUsage(ownerID int64, since time.Time) (models.UsageTotals, error) {
+	var totals models.UsageTotals
+	err := database.Retry(dbRetryAttempts, func() error {
+		var err error
+		totals, err = h.DB.SumUsage(ownerID, since)
+		return err
+	})
+	return totals, err
+}
+
+// reachedSpendCap mengembalikan batas belanja (capDaily/capMonthly) yang sudah tercapai,
+// atau string kosong jika balasan otomatis boleh berjalan.
+func (h *BotHandler) reachedSpendCap(owner *models.User) (string, error) {
+	if owner.DailySpendCap > 0 {
+		today, err := h.sumUsage(owner.TelegramID, quotaDayStart(time.Now()))
+		if err != nil {
+			return "", err
+		}
+		if today.Cost >= owner.DailySpendCap {
+			return capDaily, nil
+		}
+	}
+	if owner.MonthlySpendCap > 0 {
+		month, err := h.sumUsage(owner.TelegramID, monthStart(time.Now()))
+		if err != nil {
+			return "", err
+		}
+		if month.Cost >= owner.MonthlySpendCap {
+			return capMonthly, nil
+		}
+	}
+	return "", nil
+}
+
+// notifySpendCap memberi tahu owner sekali per periode bahwa balasan otomatis dijeda.
+func (h *BotHandler) notifySpendCap(owner *models.User, cap string) {
+	period := cap + ":" + quotaDayStart(time.Now()).Format("2006-01-02")
+	if cap == capMonthly {
+		period = cap + ":" + monthStart(time.Now()).Format("2006-01")
+	}
+	h.quotaMu.Lock()
+	if h.capNotice[owner.TelegramID] == period {
+		h.quotaMu.Unlock()
+		return
+	}
+	h.capNotice[owner.TelegramID] = period
+	h.quotaMu.Unlock()
+
+	log.Printf("System: owner %d reached the %s spending cap", owner.TelegramID, cap)
+	h.notifyOwner(owner, h.I18n.Get(owner.Language, "spend_cap_"+cap+"_reached"))
+}
+
+// parseSpendCaps membaca input "<harian> <bulanan>" dalam USD; 0 berarti tanpa batas.
+func parseSpendCaps(text string) (daily, monthly float64, ok bool) {
+	fields := strings.Fields(strings.ReplaceAll(text, ",", "."))
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	daily, err1 := strconv.ParseFloat(strings.TrimPrefix(fields[0], "$"), 64)
+	monthly, err2 := strconv.ParseFloat(strings.TrimPrefix(fields[1], "$"), 64)
+	if err1 != nil || err2 != nil || daily < 0 || monthly < 0 {
+		return 0, 0, false
+	}
+	return daily, monthly, true
+}
+
+func (h *BotHandler) formatCap(lang string, cap float64) string {
+	if cap <= 0 {
+		return h.I18n.Get(lang, "usage_cap_none")
+	}
+	return fmt.Sprintf("$%.2f", cap)
+}
+
+func (h *BotHandler) formatTotals(lang string, t models.UsageTotals) string {
+	return fmt.Sprintf(h.I18n.Get(lang, "usage_line"), t.Replies, t.PromptTokens, t.CompletionTokens, t.Cost)
+}
+
+// usageGroup adalah total pemakaian satu model atau satu pelanggan untuk layar usage.
+type usageGroup struct {
+	Label  string
+	Totals models.UsageTotals
+}
+
+// topGroups mengurutkan grup dari biaya (lalu jumlah balasan) terbesar dan membatasi jumlahnya.
+func topGroups(groups map[string]*models.UsageTotals, limit int) []usageGroup {
+	var list []usageGroup
+	for label, totals := range groups {
+		list = append(list, usageGroup{Label: label, Totals: *totals})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Totals.Cost != list[j].Totals.Cost {
+			return list[i].Totals.Cost > list[j].Totals.Cost
+		}
+		if list[i].Totals.Replies != list[j].Totals.Replies {
+			return list[i].Totals.Replies > list[j].Totals.Replies
+		}
+		return list[i].Label < list[j].Label
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// showUsage menampilkan pemakaian token dan biaya hari ini dan bulan ini, per model dan per
+// pelanggan, beserta batas belanja owner.
+func (h *BotHandler) showUsage(chatID, messageID int64, user *models.User) {
+	lang := user.Language
+	markup := map[string]interface{}{
+		"inline_keyboard": [][]map[string]interface{}{
+			{{"text": h.I18n.Get(lang, "btn_set_caps"), "callback_data": "menu_caps"}},
+			{{"text": h.I18n.Get(lang, "btn_back"), "callback_data": "back_main"}},
+		},
+	}
+
+	records, err := h.listMonthUsage(user.TelegramID)
+	if err != nil {
+		log.Printf("Storage Error: %v", err)
+		h.TG.EditMessage(chatID, messageID, h.storageErrorText(lang, err), markup)
+		return
+	}
+
+	names := h.customerNames(user.TelegramID)
+
+	dayStart := quotaDayStart(time.Now())
+	var today, month models.UsageTotals
+	byModel := make(map[string]*models.UsageTotals)
+	byCustomer := make(map[string]*models.UsageTotals)
+	unpriced := false
+	for _, rec := range records {
+		month.Add(rec)
+		if !rec.CreatedAt.Before(dayStart) {
+			today.Add(rec)
+		}
+		if byModel[rec.Model] == nil {
+			byModel[rec.Model] = &models.UsageTotals{}
+		}
+		byModel[rec.Model].Add(rec)
+
+		name := names[rec.CustomerID]
+		if name == "" {
+			name = fmt.Sprintf("User %d", rec.CustomerID)
+		}
+		if byCustomer[name] == nil {
+			byCustomer[name] = &models.UsageTotals{}
+		}
+		byCustomer[name].Add(rec)
+
+		if _, ok := h.priceOf(rec.Model); !ok {
+			unpriced = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(h.I18n.Get(lang, "usage_title") + "\n\n")
+	b.WriteString(h.I18n.Get(lang, "usage_today") + " " + h.formatTotals(lang, today) + "\n")
+	b.WriteString(h.I18n.Get(lang, "usage_month") + " " + h.formatTotals(lang, month) + "\n")
+	if len(byModel) > 0 {
+		b.WriteString("\n" + h.I18n.Get(lang, "usage_by_model") + "\n")
+		for _, g := range topGroups(byModel, 5) {
+			b.WriteString("• <code>" + html.EscapeString(g.Label) + "</code>: " + h.formatTotals(lang, g.Totals) + "\n")
+		}
+		b.WriteString("\n" + h.I18n.Get(lang, "usage_by_customer") + "\n")
+		for _, g := range topGroups(byCustomer, 5) {
+			b.WriteString("• " + html.EscapeString(g.Label) + ": " + h.formatTotals(lang, g.Totals) + "\n")
+		}
+	}
+	b.WriteString("\n" + fmt.Sprintf(h.I18n.Get(lang, "usage_caps"), h.formatCap(lang, user.DailySpendCap), h.formatCap(lang, user.MonthlySpendCap)))
+	if unpriced {
+		b.WriteString("\n\n" + h.I18n.Get(lang, "usage_no_pricing"))
+	}
+	h.TG.EditMessage(chatID, messageID, b.String(), markup)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/i18n"
+	"tg-business-bot/internal/models"
+	"time"
+)
+
+func TestParsePricing(t *testing.T) {
+	prices, err := ParsePricing("llama=0.59/0.79, *=0.5/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prices["llama"] != (ModelPrice{Input: 0.59, Output: 0.79}) || prices["*"] != (ModelPrice{Input: 0.5, Output: 1}) {
+		t.Fatalf("prices = %+v", prices)
+	}
+	for _, spec := range []string{"llama", "llama=1", "=1/2", "llama=-1/2", "llama=a/b"} {
+		if _, err := ParsePricing(spec); err == nil {
+			t.Errorf("ParsePricing(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestReachedSpendCap(t *testing.T) {
+	db := database.NewMemoryStore()
+	h := NewBotHandler(db, &fakeTelegram{}, i18n.NewBundle(), nil)
+
+	now := time.Now().UTC()
+	db.RecordUsage(models.UsageRecord{OwnerID: 1, Cost: 0.4, CreatedAt: now})
+	db.RecordUsage(models.UsageRecord{OwnerID: 1, Cost: 0.4, CreatedAt: now})
+	db.RecordUsage(models.UsageRecord{OwnerID: 2, Cost: 5, CreatedAt: now})
+
+	cases := []struct {
+		daily, monthly float64
+		want           string
+	}{
+		{0, 0, ""},
+		{1, 0, ""},
+		{0.8, 0, capDaily},
+		{0, 0.5, capMonthly},
+		{2, 10, ""},
+	}
+	for _, c := range cases {
+		owner := &models.User{TelegramID: 1, DailySpendCap: c.daily, MonthlySpendCap: c.monthly}
+		got, err := h.reachedSpendCap(owner)
+		if err != nil || got != c.want {
+			t.Errorf("caps %v/%v: got %q, %v; want %q", c.daily, c.monthly, got, err, c.want)
+		}
+	}
+}
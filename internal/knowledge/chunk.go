@@ -0,0 +1,48 @@
+package knowledge
+
+import (
+	"strings"
+	"tg-business-bot/internal/format"
+	"unicode/utf8"
+)
+
+// Ukuran potongan dalam karakter. Potongan ~800 karakter cukup untuk satu bagian menu atau
+// satu jawaban FAQ; overlap mengulang ekor potongan sebelumnya supaya kalimat yang terpotong
+// di batas tetap punya konteks.
+const (
+	ChunkSize    = 800
+	ChunkOverlap = 120
+)
+
+// Chunk memecah teks hasil Extract menjadi potongan maksimal ChunkSize karakter (ditambah
+// overlap). Potongan dipecah di batas paragraf, baris, kalimat, lalu spasi, sesuai urutan itu.
+func Chunk(text string) []string {
+	parts := format.SplitPlain(text, ChunkSize)
+	chunks := make([]string, len(parts))
+	for i, part := range parts {
+		if i > 0 {
+			if prefix := tail(parts[i-1], ChunkOverlap); prefix != "" {
+				part = "…" + prefix + "\n" + part
+			}
+		}
+		chunks[i] = part
+	}
+	return chunks
+}
+
+// tail mengembalikan paling banyak n karakter terakhir s, dimulai dari awal kata.
+func tail(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	start := len(s)
+	for count := 0; count < n; count++ {
+		_, size := utf8.DecodeLastRuneInString(s[:start])
+		start -= size
+	}
+	rest := s[start:]
+	if idx := strings.IndexAny(rest, " \n"); idx >= 0 {
+		rest = rest[idx+1:]
+	}
+	return strings.TrimSpace(rest)
+}
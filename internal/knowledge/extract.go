@@ -0,0 +1,132 @@
+// Package knowledge mengubah dokumen owner (teks, Markdown, PDF) menjadi potongan teks dan
+// mencari potongan yang relevan dengan pesan pelanggan memakai indeks BM25 lokal.
+package knowledge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	// ErrUnsupported dikembalikan untuk jenis file selain teks, Markdown dan PDF.
+	ErrUnsupported = errors.New("unsupported document type")
+	// ErrNoText berarti dokumen tidak berisi teks yang bisa dibaca (misalnya PDF hasil scan).
+	ErrNoText = errors.New("document contains no readable text")
+)
+
+// textExtensions adalah ekstensi file yang dibaca apa adanya sebagai teks UTF-8.
+var textExtensions = map[string]bool{".txt": true, ".md": true, ".markdown": true, ".csv": true}
+
+// Supported melaporkan apakah file dengan nama dan MIME type ini bisa diindeks.
+func Supported(name, mimeType string) bool {
+	return isPDF(name, mimeType) || isText(name, mimeType)
+}
+
+func isPDF(name, mimeType string) bool {
+	return mimeType == "application/pdf" || strings.EqualFold(path.Ext(name), ".pdf")
+}
+
+func isText(name, mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || textExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// Extract mengambil teks dari isi file. Baris kosong beruntun diringkas dan spasi di ujung
+// baris dibuang supaya pemotongan per paragraf bekerja sama untuk semua format.
+func Extract(name, mimeType string, data []byte) (string, error) {
+	var text string
+	switch {
+	case isPDF(name, mimeType):
+		var err error
+		if text, err = extractPDF(data); err != nil {
+			return "", err
+		}
+	case isText(name, mimeType):
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("%w: text is not UTF-8", ErrUnsupported)
+		}
+		text = string(data)
+	default:
+		return "", ErrUnsupported
+	}
+
+	text = normalize(text)
+	if text == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// normalize menyeragamkan akhir baris dan menyisakan paling banyak satu baris kosong.
+func normalize(text string) string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	var b strings.Builder
+	blank := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			blank++
+			continue
+		}
+		if b.Len() > 0 {
+			if blank > 0 {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+		blank = 0
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// extractPDF menyusun ulang teks PDF dari posisi setiap glyph: glyph dengan baris dasar
+// berbeda memulai baris baru dan celah horizontal yang lebar dianggap spasi. Parser PDF
+// bisa panik pada file rusak, jadi panic diubah menjadi error.
+func extractPDF(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("read pdf: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("read pdf: %w", err)
+	}
+
+	var b strings.Builder
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		var prev *pdf.Text
+		for _, t := range page.Content().Text {
+			t := t
+			if prev != nil {
+				size := math.Max(prev.FontSize, 1)
+				switch {
+				case math.Abs(t.Y-prev.Y) > size/2:
+					b.WriteString("\n")
+				case t.X-(prev.X+prev.W) > size*0.2:
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(t.S)
+			prev = &t
+		}
+	}
+	return b.String(), nil
+}
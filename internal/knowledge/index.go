@@ -0,0 +1,122 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"tg-business-bot/internal/models"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Parameter BM25 standar: bm25K1 mengatur kejenuhan frekuensi kata, bm25B mengatur seberapa
+// besar potongan panjang dihukum.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Index adalah indeks BM25 di memori atas potongan dokumen satu owner. Index tidak diubah
+// setelah dibuat, jadi aman dipakai beberapa goroutine sekaligus.
+type Index struct {
+	chunks []models.KnowledgeChunk
+	terms  []map[string]int
+	lens   []int
+	df     map[string]int
+	avgLen float64
+}
+
+// NewIndex membangun indeks dari potongan dokumen.
+func NewIndex(chunks []models.KnowledgeChunk) *Index {
+	idx := &Index{chunks: chunks, terms: make([]map[string]int, len(chunks)), lens: make([]int, len(chunks)), df: make(map[string]int)}
+	total := 0
+	for i, chunk := range chunks {
+		tf := make(map[string]int)
+		tokens := Tokenize(chunk.Content)
+		for _, tok := range tokens {
+			tf[tok]++
+		}
+		for tok := range tf {
+			idx.df[tok]++
+		}
+		idx.terms[i], idx.lens[i] = tf, len(tokens)
+		total += len(tokens)
+	}
+	if len(chunks) > 0 {
+		idx.avgLen = float64(total) / float64(len(chunks))
+	}
+	return idx
+}
+
+// Len mengembalikan jumlah potongan di indeks.
+func (idx *Index) Len() int {
+	return len(idx.chunks)
+}
+
+// Search mengembalikan paling banyak limit potongan dengan skor BM25 tertinggi untuk query,
+// urut dari yang paling relevan. Potongan tanpa satu pun kata query tidak dikembalikan.
+func (idx *Index) Search(query string, limit int) []models.KnowledgeChunk {
+	if len(idx.chunks) == 0 || limit <= 0 {
+		return nil
+	}
+	// Kata yang diulang di query tidak menambah bobot
+	seen := make(map[string]bool)
+	var terms []string
+	for _, tok := range Tokenize(query) {
+		if !seen[tok] && idx.df[tok] > 0 {
+			seen[tok] = true
+			terms = append(terms, tok)
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.chunks))
+	type hit struct {
+		i     int
+		score float64
+	}
+	var hits []hit
+	for i, tf := range idx.terms {
+		score := 0.0
+		for _, term := range terms {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			df := float64(idx.df[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(idx.lens[i])/idx.avgLen
+			score += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+		if score > 0 {
+			hits = append(hits, hit{i, score})
+		}
+	}
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].score > hits[b].score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	results := make([]models.KnowledgeChunk, len(hits))
+	for k, h := range hits {
+		results[k] = idx.chunks[h.i]
+	}
+	return results
+}
+
+// Tokenize memecah teks menjadi kata huruf kecil dari huruf dan angka. Kata satu huruf
+// dibuang karena hampir tidak membedakan potongan; angka tetap dipakai (nomor menu, harga).
+// Tanpa stemming supaya berlaku sama untuk semua bahasa owner.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if utf8.RuneCountInString(f) > 1 || unicode.IsNumber([]rune(f)[0]) {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,163 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"tg-business-bot/internal/models"
+	"unicode/utf8"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Nasi Goreng, Rp25.000!", []string{"nasi", "goreng", "rp25", "000"}},
+		{"a b c 7", []string{"7"}},
+		{"Привет, МИР", []string{"привет", "мир"}},
+		{"menu_2026 (baru)", []string{"menu", "2026", "baru"}},
+	}
+	for _, tt := range tests {
+		got := Tokenize(tt.text)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	if got := Chunk(""); len(got) != 0 {
+		t.Errorf("Chunk(\"\") = %q, want no chunks", got)
+	}
+	if got := Chunk("Menu singkat."); len(got) != 1 || got[0] != "Menu singkat." {
+		t.Errorf("Chunk of a short text = %q", got)
+	}
+
+	var paragraphs []string
+	for i := 0; i < 12; i++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Bagian %d. ", i)+strings.Repeat("kata ", 60))
+	}
+	text := strings.Join(paragraphs, "\n\n")
+	chunks := Chunk(text)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		// "…" + overlap + "\n" + potongan
+		if n := utf8.RuneCountInString(chunk); n > ChunkSize+ChunkOverlap+2 {
+			t.Errorf("chunk %d has %d characters", i, n)
+		}
+		if i > 0 && !strings.HasPrefix(chunk, "…") {
+			t.Errorf("chunk %d does not repeat the end of the previous chunk: %q", i, chunk[:20])
+		}
+	}
+	if strings.HasPrefix(chunks[0], "…") {
+		t.Error("first chunk has an overlap prefix")
+	}
+	for i := 0; i < 12; i++ {
+		label := fmt.Sprintf("Bagian %d.", i)
+		found := false
+		for _, chunk := range chunks {
+			if strings.Contains(chunk, label) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%q is missing from the chunks", label)
+		}
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := NewIndex([]models.KnowledgeChunk{
+		{DocumentID: 1, Position: 0, Content: "Nasi goreng spesial harga 25000"},
+		{DocumentID: 1, Position: 1, Content: "Teh manis dingin harga 5000"},
+		{DocumentID: 2, Position: 0, Content: "Jam buka setiap hari pukul 09.00"},
+	})
+	if idx.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", idx.Len())
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{"best match first", "Berapa harga nasi goreng?", 4, []string{"Nasi goreng spesial harga 25000", "Teh manis dingin harga 5000"}},
+		{"rare word wins", "harga teh", 4, []string{"Teh manis dingin harga 5000", "Nasi goreng spesial harga 25000"}},
+		{"repeated words do not count twice", "harga harga harga teh", 4, []string{"Teh manis dingin harga 5000", "Nasi goreng spesial harga 25000"}},
+		{"limit", "harga", 1, []string{"Nasi goreng spesial harga 25000"}},
+		{"other document", "jam buka", 4, []string{"Jam buka setiap hari pukul 09.00"}},
+		{"no match", "pizza", 4, nil},
+		{"empty query", "", 4, nil},
+		{"zero limit", "harga", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, chunk := range idx.Search(tt.query, tt.limit) {
+				got = append(got, chunk.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Search(%q, %d) = %q, want %q", tt.query, tt.limit, got, tt.want)
+			}
+		})
+	}
+
+	if got := NewIndex(nil).Search("harga", 4); got != nil {
+		t.Errorf("Search on an empty index = %v", got)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		mimeType string
+		data     string
+		want     string
+		wantErr  error
+	}{
+		{"text with BOM and CRLF", "menu.txt", "", "\xef\xbb\xbfNasi  \r\n\r\n\r\n\r\nTeh\r\n", "Nasi\n\nTeh", nil},
+		{"markdown by MIME type", "notes", "text/markdown", "# Menu\nNasi", "# Menu\nNasi", nil},
+		{"not UTF-8", "menu.txt", "", "\xff\xfe", "", ErrUnsupported},
+		{"image", "photo.jpg", "image/jpeg", "\xff\xd8", "", ErrUnsupported},
+		{"only whitespace", "empty.txt", "", " \n\n\t\n", "", ErrNoText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.file, tt.mimeType, []byte(tt.data))
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Extract(%q) = %q, %v, want %q, %v", tt.file, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// PDF rusak harus menjadi error, bukan panic
+	if _, err := Extract("broken.pdf", "application/pdf", []byte("%PDF-1.4 not really")); err == nil {
+		t.Error("Extract of a broken PDF succeeded")
+	}
+}
+
+func TestSupported(t *testing.T) {
+	tests := []struct {
+		name, mimeType string
+		want           bool
+	}{
+		{"MENU.PDF", "", true},
+		{"prices.csv", "", true},
+		{"readme", "text/plain", true},
+		{"data.bin", "application/octet-stream", false},
+		{"photo.jpg", "image/jpeg", false},
+	}
+	for _, tt := range tests {
+		if got := Supported(tt.name, tt.mimeType); got != tt.want {
+			t.Errorf("Supported(%q, %q) = %v, want %v", tt.name, tt.mimeType, got, tt.want)
+		}
+	}
+}
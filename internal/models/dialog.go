@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DialogState mencatat input yang sedang ditunggu bot dari owner di chat pribadi.
+// Disimpan terpisah dari User supaya data asli (prompt, key, lokasi) tidak pernah ditimpa
+// penanda sementara.
+type DialogState struct {
+	TelegramID  int64     `json:"telegram_id"`
+	Step        string    `json:"step"`         // Langkah dialog, misalnya "await_prompt"
+	Expect      string    `json:"expect"`       // Jenis input yang diterima: text, secret, url, location
+	ExpiresAt   time.Time `json:"expires_at"`   // Setelah waktu ini input dianggap ditinggalkan
+	Backup      string    `json:"backup"`       // Nilai lama yang dipulihkan jika dialog dibatalkan
+	DashboardID int64     `json:"dashboard_id"` // Pesan dashboard yang menampilkan permintaan input
+}
+
+func (d *DialogState) Expired(now time.Time) bool {
+	return now.After(d.ExpiresAt)
+}
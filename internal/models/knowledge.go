@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// KnowledgeDocument adalah dokumen (menu, daftar harga, FAQ) yang diunggah owner ke knowledge
+// base. Isinya disimpan sebagai potongan KnowledgeChunk yang dicari per pesan pelanggan.
+type KnowledgeDocument struct {
+	ID         int64     `json:"id,omitempty"`
+	OwnerID    int64     `json:"owner_id"`
+	Name       string    `json:"name"`
+	ChunkCount int       `json:"chunk_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// KnowledgeChunk adalah satu potongan teks dokumen; Position adalah urutannya di dokumen.
+type KnowledgeChunk struct {
+	DocumentID int64  `json:"document_id"`
+	OwnerID    int64  `json:"owner_id"`
+	Position   int    `json:"position"`
+	Content    string `json:"content"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ChatPause menandai percakapan yang sedang ditangani owner secara manual: balasan AI untuk
+// pelanggan ini dijeda sampai PausedUntil.
+type ChatPause struct {
+	OwnerID     int64     `json:"owner_id"`
+	CustomerID  int64     `json:"customer_id"`
+	PausedUntil time.Time `json:"paused_until"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ConversationSummary adalah ringkasan berjalan percakapan owner dengan satu pelanggan.
+// CoveredCount adalah jumlah pesan terlama (urut kronologis) yang sudah masuk ringkasan.
+type ConversationSummary struct {
+	OwnerID      int64     `json:"owner_id"`
+	CustomerID   int64     `json:"customer_id"`
+	Summary      string    `json:"summary"`
+	CoveredCount int       `json:"covered_count"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// UsageRecord mencatat satu balasan AI yang dibuat untuk owner. Dipakai untuk kuota key
+// platform dan laporan pemakaian.
+type UsageRecord struct {
+	OwnerID    int64  `json:"owner_id"`
+	CustomerID int64  `json:"customer_id"`
+	Model      string `json:"model"`
+	SharedKey  bool   `json:"shared_key"` // true jika balasan memakai key platform (GROQ_API_KEY)
+	// Token yang dilaporkan provider dan biayanya (USD) sesuai harga saat balasan dibuat
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageTotals adalah ringkasan pemakaian owner dalam satu periode. Untuk kuota dan batas belanja
+// dihitung oleh storage (SUM/COUNT) supaya tidak memuat semua UsageRecord di setiap pesan.
+type UsageTotals struct {
+	Replies          int     `json:"replies"`
+	SharedReplies    int     `json:"shared_replies"` // balasan yang memakai key platform
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add menambahkan satu catatan pemakaian ke total.
+func (t *UsageTotals) Add(rec UsageRecord) {
+	t.Replies++
+	if rec.SharedKey {
+		t.SharedReplies++
+	}
+	t.PromptTokens += rec.PromptTokens
+	t.CompletionTokens += rec.CompletionTokens
+	t.Cost += rec.Cost
+}
@@ -1,19 +1,44 @@
 package models
 
+// SecretAPIKey adalah nama field yang dipakai sebagai associated data saat EncryptedGroqKey
+// dienkripsi, supaya ciphertext tidak bisa dipindah ke baris user lain.
+const SecretAPIKey = "api_key"
+
 type User struct {
 	TelegramID      int64  `json:"telegram_id"`
 	Username        string `json:"username"`
+	FirstName       string `json:"first_name"` // Profil Telegram owner, untuk {{owner_name}}
+	LastName        string `json:"last_name"`
 	Language        string `json:"language"`
-	EncryptedGroqKey string `json:"encrypted_groq_key"`
+	EncryptedGroqKey string `json:"encrypted_groq_key"` // API key provider AI yang dipilih (nama kolom lama)
+	KeyStatus        string `json:"key_status"`         // Hasil cek terakhir: valid, invalid, revoked, rate_limited, network_error
+	KeyCheckedAt     string `json:"key_checked_at,omitempty"`
 	IsPremium       bool   `json:"is_premium"`
 	BusinessConnID  string `json:"business_connection_id"`
+	BusinessConnEnabled bool  `json:"business_connection_enabled"`
+	BusinessConnDate    int64 `json:"business_connection_date"`
+	BusinessUserChatID  int64 `json:"business_user_chat_id"` // Chat pribadi owner dari koneksi bisnis
+	BusinessCanReply    bool  `json:"business_can_reply"`
+	BusinessCanReadMessages bool `json:"business_can_read_messages"`
+	BusinessCanDeleteMessages bool `json:"business_can_delete_messages"`
 	SystemPrompt    string `json:"system_prompt"`
 	AIModel         string `json:"ai_model"`
+	AIProvider      string `json:"ai_provider"` // groq, openai atau local
+	AIBaseURL       string `json:"ai_base_url"` // Endpoint OpenAI-compatible milik owner
 	LastDashboardID int64  `json:"last_dashboard_id"`
 	CreatedAt       string `json:"created_at,omitempty"`
 	BusinessLocation string `json:"business_location"`
     Latitude         float64 `json:"latitude"`
     Longitude        float64 `json:"longitude"`
+	DailySpendCap    float64 `json:"daily_spend_cap"`   // USD per hari; 0 = tanpa batas
+	MonthlySpendCap  float64 `json:"monthly_spend_cap"` // USD per bulan kalender; 0 = tanpa batas
+	Timezone         string  `json:"timezone"`          // Zona waktu IANA, mis. Asia/Jakarta; kosong = UTC
+	BusinessHours    string  `json:"business_hours"`    // Jam buka mingguan (format: lihat schedule.Parse); kosong = selalu buka
+	Holidays         string  `json:"holidays"`          // Tanggal libur, mis. "2026-12-25, 2026-12-31..2027-01-01"
+	AwayMode         string  `json:"away_mode"`         // Perilaku di luar jam buka: kosong (AI biasa), prompt, message, silent
+	AwayPrompt       string  `json:"away_prompt"`       // Prompt pengganti saat AwayMode = prompt
+	AwayMessage      string  `json:"away_message"`      // Pesan tetap saat AwayMode = message
+	PromptVars       map[string]string `json:"prompt_vars"` // Variabel prompt buatan owner, mis. promo_code
 }
 
 type ChatMessage struct {
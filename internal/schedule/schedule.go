@@ -0,0 +1,293 @@
+// Package schedule mengurai jam buka mingguan dan hari libur owner, lalu menjawab apakah
+// bisnis sedang buka dan kapan buka berikutnya di zona waktu owner.
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayout adalah format tanggal hari libur.
+const dateLayout = "2006-01-02"
+
+// maxHolidayRange membatasi rentang hari libur supaya input salah ketik tidak membuat
+// ribuan entri.
+const maxHolidayRange = 366
+
+// searchDays adalah jangkauan pencarian NextOpening (satu tahun plus satu minggu).
+const searchDays = 372
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Interval adalah rentang buka dalam satu hari, dalam menit sejak tengah malam [Start, End).
+type Interval struct {
+	Start int
+	End   int
+}
+
+func (iv Interval) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", iv.Start/60, iv.Start%60, iv.End/60, iv.End%60)
+}
+
+// Schedule adalah jam buka per hari (indeks time.Weekday) dan daftar hari libur.
+type Schedule struct {
+	Location *time.Location
+	Week     [7][]Interval
+	Holidays map[string]bool
+}
+
+// LoadLocation memuat zona waktu IANA; kosong berarti UTC.
+func LoadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	// "Local" bergantung pada zona server, justru yang ingin dihindari
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// Parse membuat Schedule dari teks jam buka, hari libur dan nama zona waktu. Jam buka kosong
+// menghasilkan nil: bisnis dianggap selalu buka.
+//
+// Format jam buka: satu aturan per baris atau dipisah ";", misalnya
+// "mon-fri 09:00-17:00; sat 10:00-14:00 15:00-18:00; sun closed". Hari bisa berupa "mon",
+// rentang "mon-fri", daftar "mon,wed" atau "daily"; jam tutup sebelum jam buka berarti lewat
+// tengah malam. Aturan berikutnya menambah rentang, kecuali "closed" yang mengosongkan hari itu.
+// Format hari libur: tanggal "2026-12-25" atau rentang "2026-12-24..2026-12-26", dipisah
+// koma atau baris baru.
+func Parse(hours, holidays, timezone string) (*Schedule, error) {
+	if strings.TrimSpace(hours) == "" {
+		return nil, nil
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schedule{Location: loc}
+	if err := s.parseHours(hours); err != nil {
+		return nil, err
+	}
+	if s.Holidays, err = ParseHolidays(holidays); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schedule) parseHours(text string) error {
+	// Sisa rentang yang lewat tengah malam digabung terakhir, supaya "sun closed" tidak
+	// memotong jam buka Sabtu malam
+	var spill [7][]Interval
+	rules := strings.FieldsFunc(text, func(r rune) bool { return r == ';' || r == '\n' })
+	for _, rule := range rules {
+		fields := strings.Fields(strings.ToLower(rule))
+		if len(fields) == 0 {
+			continue
+		}
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return err
+		}
+		if len(fields) == 1 {
+			return fmt.Errorf("missing hours for %q", fields[0])
+		}
+		if len(fields) == 2 && fields[1] == "closed" {
+			for _, d := range days {
+				s.Week[d] = nil
+			}
+			continue
+		}
+		for _, field := range fields[1:] {
+			start, end, err := parseRange(field)
+			if err != nil {
+				return err
+			}
+			for _, d := range days {
+				if end > start {
+					s.Week[d] = append(s.Week[d], Interval{start, end})
+					continue
+				}
+				// Lewat tengah malam: sisanya masuk ke hari berikutnya
+				s.Week[d] = append(s.Week[d], Interval{start, 24 * 60})
+				if end > 0 {
+					next := (d + 1) % 7
+					spill[next] = append(spill[next], Interval{0, end})
+				}
+			}
+		}
+	}
+	for d := range s.Week {
+		s.Week[d] = append(s.Week[d], spill[d]...)
+		sort.Slice(s.Week[d], func(i, j int) bool { return s.Week[d][i].Start < s.Week[d][j].Start })
+	}
+	return nil
+}
+
+func parseDay(name string) (int, error) {
+	if len(name) >= 3 {
+		for i, d := range dayNames {
+			if strings.HasPrefix(name, d) {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", name)
+}
+
+// parseDays mengurai "mon", "mon-fri", "fri-mon", "mon,wed,fri" atau "daily".
+func parseDays(spec string) ([]int, error) {
+	if spec == "daily" {
+		return []int{0, 1, 2, 3, 4, 5, 6}, nil
+	}
+	var days []int
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := parseDay(from)
+		if err != nil {
+			return nil, err
+		}
+		if !isRange {
+			days = append(days, first)
+			continue
+		}
+		last, err := parseDay(to)
+		if err != nil {
+			return nil, err
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseRange mengurai "09:00-17:00" menjadi menit sejak tengah malam.
+func parseRange(field string) (int, int, error) {
+	from, to, ok := strings.Cut(field, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid hours %q", field)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start == end || start == 24*60 {
+		return 0, 0, fmt.Errorf("invalid hours %q", field)
+	}
+	if end == 24*60 {
+		end = 0
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.Atoi(hh)
+	m, err2 := strconv.Atoi(mm)
+	if err1 != nil || err2 != nil || len(mm) != 2 || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// ParseHolidays mengurai daftar tanggal dan rentang tanggal hari libur.
+func ParseHolidays(text string) (map[string]bool, error) {
+	holidays := make(map[string]bool)
+	fields := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' || r == '\n' || r == ' ' })
+	for _, field := range fields {
+		from, to, isRange := strings.Cut(field, "..")
+		start, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", from)
+		}
+		end := start
+		if isRange {
+			if end, err = time.Parse(dateLayout, to); err != nil {
+				return nil, fmt.Errorf("invalid date %q", to)
+			}
+		}
+		if end.Before(start) || end.Sub(start) > maxHolidayRange*24*time.Hour {
+			return nil, fmt.Errorf("invalid date range %q", field)
+		}
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			holidays[d.Format(dateLayout)] = true
+		}
+	}
+	return holidays, nil
+}
+
+// IsOpen melaporkan apakah bisnis buka pada waktu t. Schedule nil berarti selalu buka.
+func (s *Schedule) IsOpen(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	lt := t.In(s.Location)
+	if s.Holidays[lt.Format(dateLayout)] {
+		return false
+	}
+	minute := lt.Hour()*60 + lt.Minute()
+	for _, iv := range s.Week[lt.Weekday()] {
+		if minute >= iv.Start && minute < iv.End {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpening mengembalikan waktu buka berikutnya setelah t, di zona waktu owner. ok bernilai
+// false jika tidak ada jadwal buka dalam setahun ke depan.
+func (s *Schedule) NextOpening(t time.Time) (time.Time, bool) {
+	if s == nil {
+		return t, true
+	}
+	lt := t.In(s.Location)
+	for d := 0; d < searchDays; d++ {
+		day := time.Date(lt.Year(), lt.Month(), lt.Day()+d, 0, 0, 0, 0, s.Location)
+		if s.Holidays[day.Format(dateLayout)] {
+			continue
+		}
+		for _, iv := range s.Week[day.Weekday()] {
+			start := time.Date(day.Year(), day.Month(), day.Day(), iv.Start/60, iv.Start%60, 0, 0, s.Location)
+			// Lanjutan rentang dari hari sebelumnya bukan "pembukaan" baru
+			if iv.Start == 0 && s.IsOpen(start.Add(-time.Minute)) {
+				continue
+			}
+			if start.After(t) {
+				return start, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Lines menampilkan jam buka per hari mulai Senin, misalnya "mon 09:00-17:00".
+func (s *Schedule) Lines() []string {
+	var lines []string
+	for i := 1; i <= 7; i++ {
+		d := i % 7
+		line := dayNames[d]
+		if len(s.Week[d]) == 0 {
+			line += " closed"
+		}
+		for _, iv := range s.Week[d] {
+			line += " " + iv.String()
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,151 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+const testZone = "Asia/Jakarta"
+
+func mustParse(t *testing.T, hours, holidays string) *Schedule {
+	t.Helper()
+	s, err := Parse(hours, holidays, testZone)
+	if err != nil {
+		t.Fatalf("Parse(%q, %q): %v", hours, holidays, err)
+	}
+	return s
+}
+
+// at membuat waktu lokal di testZone, misalnya at(t, "2026-10-19 09:00").
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation(testZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestIsOpen(t *testing.T) {
+	// 2026-10-16 adalah hari Jumat
+	s := mustParse(t, "mon-fri 09:00-17:00; fri-sat 20:00-02:00; sun closed", "2026-12-25, 2026-12-31..2027-01-01")
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2026-10-19 10:00", true},
+		{"2026-10-19 08:59", false},
+		{"2026-10-19 17:00", false},
+		{"2026-10-16 23:30", true},
+		{"2026-10-17 01:30", true}, // Jumat malam lewat tengah malam
+		{"2026-10-17 02:00", false},
+		{"2026-10-18 01:00", true}, // sisa Sabtu malam tetap buka walau "sun closed"
+		{"2026-10-18 12:00", false},
+		{"2026-12-25 10:00", false},
+		{"2026-12-31 10:00", false},
+		{"2027-01-01 10:00", false},
+	}
+	for _, tt := range tests {
+		if got := s.IsOpen(at(t, tt.at)); got != tt.want {
+			t.Errorf("IsOpen(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	// Waktu dari zona lain dibandingkan di zona owner: 02:00 UTC = 09:00 WIB
+	if !s.IsOpen(time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)) {
+		t.Error("IsOpen ignores the owner's time zone")
+	}
+}
+
+func TestNextOpening(t *testing.T) {
+	overnight := mustParse(t, "mon-fri 09:00-17:00; fri-sat 20:00-02:00; sun closed", "")
+	office := mustParse(t, "mon-fri 09:00-17:00", "2026-12-25, 2026-12-31..2027-01-01")
+	tests := []struct {
+		name string
+		s    *Schedule
+		from string
+		want string
+	}{
+		{"later today", office, "2026-10-19 08:00", "2026-10-19 09:00"},
+		{"open now gives the next day", office, "2026-10-19 12:00", "2026-10-20 09:00"},
+		{"evening opening", overnight, "2026-10-16 18:00", "2026-10-16 20:00"},
+		{"after midnight is not a new opening", overnight, "2026-10-17 01:00", "2026-10-17 20:00"},
+		{"skips the spill into sunday", overnight, "2026-10-17 21:00", "2026-10-19 09:00"},
+		{"skips a holiday", office, "2026-12-24 18:00", "2026-12-28 09:00"},
+		{"skips a holiday range", office, "2026-12-30 17:30", "2027-01-04 09:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.s.NextOpening(at(t, tt.from))
+			if want := at(t, tt.want); !ok || !got.Equal(want) {
+				t.Errorf("NextOpening(%s) = %s, %v, want %s", tt.from, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextOpeningNeverOpen(t *testing.T) {
+	s := mustParse(t, "daily closed", "")
+	if _, ok := s.NextOpening(at(t, "2026-10-19 08:00")); ok {
+		t.Error("NextOpening found an opening in a schedule that is always closed")
+	}
+}
+
+func TestNilScheduleAlwaysOpen(t *testing.T) {
+	s, err := Parse("  ", "", testZone)
+	if err != nil || s != nil {
+		t.Fatalf("Parse of empty hours = %v, %v, want nil, nil", s, err)
+	}
+	now := at(t, "2026-10-18 03:00")
+	if !s.IsOpen(now) {
+		t.Error("nil schedule is closed")
+	}
+	if next, ok := s.NextOpening(now); !ok || !next.Equal(now) {
+		t.Errorf("NextOpening on nil schedule = %s, %v", next, ok)
+	}
+}
+
+func TestLines(t *testing.T) {
+	s := mustParse(t, "mon-fri 09:00-17:00; fri-sat 20:00-24:00; sat 00:00-02:00; sun closed", "")
+	want := []string{
+		"mon 09:00-17:00",
+		"tue 09:00-17:00",
+		"wed 09:00-17:00",
+		"thu 09:00-17:00",
+		"fri 09:00-17:00 20:00-24:00",
+		"sat 00:00-02:00 20:00-24:00",
+		"sun closed",
+	}
+	if got := s.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		hours, holidays, timezone string
+	}{
+		{"mon 9-17", "", ""},
+		{"xyz 09:00-17:00", "", ""},
+		{"mon", "", ""},
+		{"mon 09:00-09:00", "", ""},
+		{"mon 09:60-17:00", "", ""},
+		{"mon 24:30-02:00", "", ""},
+		{"mon 09:00-17:00", "2026-13-01", ""},
+		{"mon 09:00-17:00", "2026-12-26..2026-12-25", ""},
+		{"mon 09:00-17:00", "2026-01-01..2027-06-01", ""},
+		{"mon 09:00-17:00", "", "Mars/Olympus"},
+		{"mon 09:00-17:00", "", "Local"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.hours, tt.holidays, tt.timezone); err == nil {
+			t.Errorf("Parse(%q, %q, %q) succeeded, want an error", tt.hours, tt.holidays, tt.timezone)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"tg-business-bot/internal/encryption"
+)
+
+// newKeyProvider memilih sumber master key sesuai ENCRYPTION_KEY_PROVIDER. KMS dikembalikan
+// untuk provider kms-local supaya data key lama di ENCRYPTION_OLD_KEYS juga bisa dibuka.
+func newKeyProvider(cfg *Config) (encryption.KeyProvider, encryption.KMS, error) {
+	switch cfg.KeyProvider {
+	case "file":
+		provider, err := encryption.NewFileProvider(cfg.EncryptionKeyFile, cfg.EncryptionKDF, cfg.EncryptionKeySalt)
+		return provider, nil, err
+	case "kms-local":
+		kms, err := encryption.NewLocalKMS(cfg.KMSMasterKey, cfg.EncryptionKeySalt)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &encryption.EnvelopeProvider{KMS: kms, WrappedKey: cfg.WrappedDataKey}, kms, nil
+	default:
+		provider, err := encryption.NewEnvProvider("ENCRYPTION_KEY", cfg.EncryptionKDF, cfg.EncryptionKeySalt)
+		return provider, nil, err
+	}
+}
+
+// loadKeyring membangun keyring dari key utama milik provider dan key lama untuk rotasi.
+func loadKeyring(cfg *Config) (*encryption.Keyring, error) {
+	provider, kms, err := newKeyProvider(cfg)
+	if err != nil {
+		return nil, saltHint(err)
+	}
+	if p, ok := provider.(*encryption.PassphraseProvider); ok && p.KDF == encryption.KDFNone {
+		log.Printf("Warning: Encryption key is used raw without a KDF; set ENCRYPTION_KDF=%s and rotate to harden it", encryption.KDFArgon2)
+	}
+
+	keys, err := encryption.NewKeyring(provider, cfg.EncryptionKeyID)
+	if err != nil {
+		return nil, saltHint(err)
+	}
+	if err := keys.AddOldKeys(cfg.EncryptionOldKeys, cfg.EncryptionKeySalt, kms); err != nil {
+		return nil, saltHint(err)
+	}
+	keys.AllowUnbound = cfg.AllowUnbound
+	if keys.AllowUnbound {
+		log.Println("Warning: ENCRYPTION_ALLOW_UNBOUND is on; secrets that are not bound to their owner are still accepted. Run rotate-keys, then turn it off")
+	}
+	return keys, nil
+}
+
+// saltHint menambahkan petunjuk untuk operator jika Argon2id dipakai tanpa ENCRYPTION_KEY_SALT.
+func saltHint(err error) error {
+	if errors.Is(err, encryption.ErrMissingSalt) {
+		return fmt.Errorf("%w; set ENCRYPTION_KEY_SALT (create it once with \"generate-key-salt\")", err)
+	}
+	return err
+}
+
+// generateKeySalt mencetak salt acak untuk ENCRYPTION_KEY_SALT. Salt dibuat sekali per deployment
+// dan disimpan bersama key; menggantinya mengubah semua key yang diturunkan dengan Argon2id.
+func generateKeySalt() error {
+	salt, err := encryption.GenerateSalt()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ENCRYPTION_KEY_SALT=%s\n", base64.StdEncoding.EncodeToString(salt))
+	return nil
+}
+
+// generateDataKey mencetak data key baru yang terikat ke keyID (ID key berikutnya saat rotasi).
+func generateDataKey(cfg *Config, keyID string) error {
+	if cfg.KeyProvider != "kms-local" {
+		return fmt.Errorf("ENCRYPTION_KEY_PROVIDER must be kms-local, got %s", cfg.KeyProvider)
+	}
+	kms, err := encryption.NewLocalKMS(cfg.KMSMasterKey, cfg.EncryptionKeySalt)
+	if err != nil {
+		return saltHint(err)
+	}
+	wrapped, err := encryption.GenerateDataKey(kms, keyID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ENCRYPTION_KEY_ID=%s\nENCRYPTION_WRAPPED_KEY=%s\n", keyID, wrapped)
+	return nil
+}
@@ -1,21 +1,63 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"tg-business-bot/internal/api"
 	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/dispatcher"
 	"tg-business-bot/internal/handlers"
 	"tg-business-bot/internal/i18n"
 	"time"
 )
 
+// pollTimeout adalah lama long polling getUpdates sebelum Telegram menjawab tanpa update.
+const pollTimeout = 30 * time.Second
+
 func main() {
+	// "generate-key-salt" mencetak salt baru untuk ENCRYPTION_KEY_SALT; tidak butuh konfigurasi lain
+	if len(os.Args) > 1 && os.Args[1] == "generate-key-salt" {
+		if err := generateKeySalt(); err != nil {
+			log.Fatalf("Critical Error: Failed to generate key salt: %v", err)
+		}
+		return
+	}
+
 	cfg := LoadConfig()
 
-	db := database.NewSupabaseClient(cfg.SupabaseURL, cfg.SupabaseServiceKey)
+	// "generate-data-key [keyID]" mencetak data key baru yang dibungkus KMS untuk
+	// ENCRYPTION_WRAPPED_KEY, terikat ke keyID (default ENCRYPTION_KEY_ID)
+	if len(os.Args) > 1 && os.Args[1] == "generate-data-key" {
+		keyID := cfg.EncryptionKeyID
+		if len(os.Args) > 2 {
+			keyID = os.Args[2]
+		}
+		if err := generateDataKey(cfg, keyID); err != nil {
+			log.Fatalf("Critical Error: Failed to generate data key: %v", err)
+		}
+		return
+	}
+
+	db, err := database.NewStore(cfg.StorageDriver, cfg.SupabaseURL, cfg.SupabaseServiceKey, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Critical Error: Failed to open %s storage: %v", cfg.StorageDriver, err)
+	}
+	keys, err := loadKeyring(cfg)
+	if err != nil {
+		log.Fatalf("Critical Error: Invalid encryption keys: %v", err)
+	}
+
+	// "rotate-keys" mengenkripsi ulang semua secret dengan key utama, lalu keluar
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		if err := rotateKeys(db, keys); err != nil {
+			log.Fatalf("Critical Error: Key rotation failed: %v", err)
+		}
+		return
+	}
+
 	tg := api.NewTelegramClient(cfg.BotToken)
 	
 	// Inisialisasi Bundle Bahasa
@@ -32,37 +74,99 @@ func main() {
 		log.Printf("Warning: Failed to load Russian: %v", err)
 	}
 
-	handler := handlers.NewBotHandler(db, tg, bundle, cfg.EncryptionKey)
+	handler := handlers.NewBotHandler(db, tg, bundle, keys)
+	handler.LocalAIURL = cfg.LocalAIURL
+	prices, err := handlers.ParsePricing(cfg.ModelPricing)
+	if err != nil {
+		log.Fatalf("Critical Error: Invalid MODEL_PRICING: %v", err)
+	}
+	handler.Prices = prices
+	handler.HistoryMaxTokens = cfg.HistoryMaxTokens
+	handler.ReplyReserveTokens = cfg.ReplyReserveTokens
+	handler.SummaryThreshold = cfg.SummaryThreshold
+	handler.SummaryKeepRecent = cfg.SummaryKeepRecent
+	if cfg.StreamReplies {
+		handler.StreamEditInterval = cfg.StreamEditInterval
+	}
+	handler.TakeoverCooldown = cfg.TakeoverCooldown
+	handler.KnowledgeMaxTokens = cfg.KnowledgeMaxTokens
+	handler.SharedKey = cfg.GroqMasterKey
+	handler.SharedDailyQuota = cfg.SharedKeyQuota
+	if cfg.GroqMasterKey != "" {
+		log.Printf("System: Shared Groq key enabled for owners without a key. Daily quota: %d replies", cfg.SharedKeyQuota)
+	}
+	disp := dispatcher.NewDispatcher(cfg.WorkerCount, cfg.WorkerQueueSize, handler.HandleUpdate)
+
+	// SIGINT/SIGTERM menghentikan penerimaan update baru, lalu update yang sedang diproses dituntaskan
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Batalkan input dashboard yang ditinggalkan owner
+	go handler.RunDialogJanitor(ctx, time.Minute)
+	// Cek ulang API key owner secara berkala supaya key yang dicabut cepat ketahuan
+	go handler.RunKeyChecker(ctx, cfg.KeyCheckInterval)
+
+	if cfg.UpdateMode == "webhook" {
+		runWebhook(ctx, cfg, tg, disp)
+	} else {
+		runPolling(ctx, cfg, tg, db, disp)
+	}
+	// Ringkasan percakapan yang sedang diperbarui di latar belakang dituntaskan dulu
+	handler.Wait()
+	log.Println("System: Shutdown complete")
+}
+
+func runPolling(ctx context.Context, cfg *Config, tg *api.TelegramClient, db database.Store, disp *dispatcher.Dispatcher) {
+	// getUpdates ditolak Telegram selama masih ada webhook aktif
+	if err := tg.DeleteWebhook(); err != nil {
+		log.Printf("Warning: Failed to delete webhook: %v", err)
+	}
+
+	offset, err := db.GetUpdateOffset()
+	if err != nil {
+		log.Printf("Warning: Failed to load saved update offset, starting from Telegram's queue: %v", err)
+	}
+	saved := offset
 
-	log.Println("Bot Engine Started: Polling for updates...")
+	log.Printf("Bot Engine Started: Polling for updates from offset %d...", offset)
 
-	offset := int64(0)
-	for {
-		updates, err := getUpdates(cfg.BotToken, offset)
+	for ctx.Err() == nil {
+		updates, err := tg.GetUpdates(ctx, offset, pollTimeout)
 		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
 			log.Printf("Polling Error: %v", err)
-			time.Sleep(5 * time.Second)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
 			continue
 		}
 
 		for _, update := range updates {
-			handler.HandleUpdate(update)
+			disp.Submit(update)
 			offset = update.UpdateID + 1
 		}
+
+		saved = saveOffset(db, disp.Committed(), saved)
 	}
-}
 
-func getUpdates(token string, offset int64) ([]api.Update, error) {
-	apiUrl := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=30", token, offset)
-	resp, err := http.Get(apiUrl)
-	if err != nil { return nil, err }
-	defer resp.Body.Close()
+	log.Println("System: Shutting down, waiting for in-flight updates...")
+	disp.Stop()
+	saveOffset(db, disp.Committed(), saved)
+}
 
-	var result struct {
-		OK     bool         `json:"ok"`
-		Result []api.Update `json:"result"`
+// saveOffset menyimpan offset committed (semua update di bawahnya sudah selesai diproses) jika
+// lebih maju dari yang tersimpan, lalu mengembalikan offset yang kini tersimpan. Committed bernilai
+// 0 selama belum ada update yang masuk, dan itu tidak boleh menimpa offset hasil restart sebelumnya.
+func saveOffset(db interface{ SaveUpdateOffset(int64) error }, committed, saved int64) int64 {
+	if committed <= saved {
+		return saved
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil { return nil, err }
-
-	return result.Result, nil
-}
\ No newline at end of file
+	if err := db.SaveUpdateOffset(committed); err != nil {
+		log.Printf("Warning: Failed to save update offset: %v", err)
+		return saved
+	}
+	return committed
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+type offsetRecorder struct {
+	saved []int64
+	err   error
+}
+
+func (r *offsetRecorder) SaveUpdateOffset(offset int64) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.saved = append(r.saved, offset)
+	return nil
+}
+
+func TestSaveOffsetOnlyMovesForward(t *testing.T) {
+	db := &offsetRecorder{}
+
+	// Belum ada update yang diproses: offset hasil restart tidak boleh ditimpa 0
+	if got := saveOffset(db, 0, 500); got != 500 || len(db.saved) != 0 {
+		t.Fatalf("saveOffset(0, 500) = %d, saved %v", got, db.saved)
+	}
+	if got := saveOffset(db, 503, 500); got != 503 || len(db.saved) != 1 || db.saved[0] != 503 {
+		t.Fatalf("saveOffset(503, 500) = %d, saved %v", got, db.saved)
+	}
+	if got := saveOffset(db, 503, 503); got != 503 || len(db.saved) != 1 {
+		t.Fatalf("saveOffset(503, 503) = %d, saved %v", got, db.saved)
+	}
+
+	db.err = errors.New("down")
+	if got := saveOffset(db, 510, 503); got != 503 {
+		t.Fatalf("saveOffset with failing store = %d, want 503", got)
+	}
+}
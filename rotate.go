@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/encryption"
+	"tg-business-bot/internal/models"
+)
+
+// rotateKeys mengenkripsi ulang API key setiap owner dengan key utama dan mengikatnya ke
+// pemiliknya (ciphertext lama yang belum terikat ikut dimigrasi). Alur rotasi:
+//  1. pindahkan key lama ke ENCRYPTION_OLD_KEYS (mis. "k1:<key lama>", atau untuk kms-local
+//     "k1:wrapped:<ENCRYPTION_WRAPPED_KEY lama>"),
+//  2. isi ENCRYPTION_KEY (kms-local: ENCRYPTION_WRAPPED_KEY dari "generate-data-key k2") dan
+//     ENCRYPTION_KEY_ID dengan key baru,
+//  3. jalankan "rotate-keys", lalu key lama boleh dihapus dari ENCRYPTION_OLD_KEYS.
+//
+// Perintah ini aman diulang: secret yang sudah terikat dan memakai key utama dilewati. Ciphertext
+// baru hanya disimpan jika key owner belum berubah sejak dibaca, jadi key yang diganti owner
+// selama rotasi tidak tertimpa. Ciphertext lama yang belum terikat selalu bisa dibuka di sini,
+// apa pun nilai ENCRYPTION_ALLOW_UNBOUND.
+func rotateKeys(db database.Store, keys *encryption.Keyring) error {
+	// Rotasi justru bertugas mengikat ciphertext lama, jadi selalu boleh membukanya
+	keys.AllowUnbound = true
+
+	users, err := db.ListUsers()
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+
+	rotated, skipped, failed := 0, 0, 0
+	for _, user := range users {
+		if user.EncryptedGroqKey == "" || !keys.NeedsRotation(user.EncryptedGroqKey) {
+			skipped++
+			continue
+		}
+
+		binding := encryption.Binding(user.TelegramID, models.SecretAPIKey)
+		plainText, err := keys.Decrypt(user.EncryptedGroqKey, binding)
+		if err != nil {
+			log.Printf("Warning: Cannot decrypt API key of user %d: %v", user.TelegramID, err)
+			failed++
+			continue
+		}
+		cipherText, err := keys.Encrypt(plainText, binding)
+		if err != nil {
+			return fmt.Errorf("encrypt key of user %d: %w", user.TelegramID, err)
+		}
+
+		// Snapshot ListUsers bisa sudah lama; store hanya menulis kolom key dan hanya jika
+		// ciphertext yang tersimpan masih yang tadi didekripsi
+		var updated bool
+		if err := database.Retry(3, func() error {
+			var err error
+			updated, err = db.ReplaceEncryptedKey(user.TelegramID, user.EncryptedGroqKey, cipherText)
+			return err
+		}); err != nil {
+			log.Printf("Storage Error: Failed to save rotated key of user %d: %v", user.TelegramID, err)
+			failed++
+			continue
+		}
+		if !updated {
+			// Owner mengganti key selama rotasi; dihitung gagal supaya perintah ini diulang
+			// sebelum key lama dihapus
+			log.Printf("Warning: API key of user %d changed during rotation, run rotate-keys again", user.TelegramID)
+			failed++
+			continue
+		}
+		rotated++
+	}
+
+	log.Printf("System: Key rotation to %q finished. Rotated: %d, Skipped: %d, Failed: %d", keys.PrimaryID(), rotated, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d secrets could not be rotated; keep the old keys configured and run again", failed)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"tg-business-bot/internal/database"
+	"tg-business-bot/internal/encryption"
+	"tg-business-bot/internal/models"
+)
+
+func TestRotateKeys(t *testing.T) {
+	keys, err := encryption.NewKeyring(&encryption.PassphraseProvider{Passphrase: "fedcba9876543210fedcba9876543210", KDF: encryption.KDFNone}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.AddOldKeys("k1:0123456789abcdef0123456789abcdef", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Ciphertext v1 dari versi sebelum pengikatan; rotasi harus bisa membukanya meskipun
+	// ENCRYPTION_ALLOW_UNBOUND mati
+	legacy, err := encryption.Encrypt("gsk_secret", "0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCipher := "v1:k1:" + legacy
+
+	db := database.NewMemoryStore()
+	db.UpsertUser(models.User{TelegramID: 1, EncryptedGroqKey: oldCipher, SystemPrompt: "keep me"})
+	db.UpsertUser(models.User{TelegramID: 2})
+
+	if err := rotateKeys(db, keys); err != nil {
+		t.Fatalf("rotateKeys: %v", err)
+	}
+	user, _ := db.GetUser(1)
+	if keys.NeedsRotation(user.EncryptedGroqKey) {
+		t.Fatalf("key of user 1 still needs rotation: %q", user.EncryptedGroqKey)
+	}
+	if plain, err := keys.Decrypt(user.EncryptedGroqKey, encryption.Binding(1, models.SecretAPIKey)); plain != "gsk_secret" || err != nil {
+		t.Fatalf("rotated key decrypts to %q, %v", plain, err)
+	}
+	if user.SystemPrompt != "keep me" {
+		t.Fatalf("rotation overwrote other fields: %+v", user)
+	}
+
+	// Perintah yang diulang tidak mengubah apa pun
+	rotated := user.EncryptedGroqKey
+	if err := rotateKeys(db, keys); err != nil {
+		t.Fatalf("second rotateKeys: %v", err)
+	}
+	if user, _ = db.GetUser(1); user.EncryptedGroqKey != rotated {
+		t.Fatal("second run re-encrypted an already rotated key")
+	}
+}
@@ -0,0 +1,53 @@
+-- Skema Supabase untuk SupabaseClient (internal/database/supabase.go). Aman dijalankan ulang:
+-- tabel yang sudah ada dari versi lama hanya ditambah kolom yang belum ada.
+-- Jalankan dengan "supabase db push" atau tempel di SQL Editor.
+
+CREATE TABLE IF NOT EXISTS users (
+	telegram_id BIGINT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS username TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS encrypted_groq_key TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS is_premium BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS business_connection_id TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS business_connection_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS business_connection_date BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS business_can_reply BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS business_can_read_messages BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS business_can_delete_messages BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS system_prompt TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS ai_model TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS last_dashboard_id BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS business_location TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS users_business_connection_id_idx ON users (business_connection_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL,
+	customer_id BIGINT NOT NULL,
+	customer_name TEXT NOT NULL DEFAULT '',
+	role TEXT NOT NULL,
+	content TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE messages
+	ADD COLUMN IF NOT EXISTS customer_name TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS messages_owner_customer_idx ON messages (owner_id, customer_id, created_at);
+
+CREATE TABLE IF NOT EXISTS bot_state (
+	key TEXT PRIMARY KEY,
+	value BIGINT NOT NULL
+);
+
+-- Bot memakai service role key yang melewati RLS; RLS tanpa policy menutup tabel dari anon key.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE messages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE bot_state ENABLE ROW LEVEL SECURITY;
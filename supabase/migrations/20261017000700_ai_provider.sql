@@ -0,0 +1,4 @@
+-- Provider AI pilihan owner. encrypted_groq_key tetap dipakai untuk key provider apa pun.
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS ai_provider TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS ai_base_url TEXT NOT NULL DEFAULT '';
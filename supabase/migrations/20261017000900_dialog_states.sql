@@ -0,0 +1,14 @@
+-- Input yang sedang ditunggu dari owner. Disimpan dengan upsert (resolution=merge-duplicates),
+-- jadi telegram_id harus primary key.
+CREATE TABLE IF NOT EXISTS dialog_states (
+	telegram_id BIGINT PRIMARY KEY,
+	step TEXT NOT NULL,
+	expect TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL,
+	backup TEXT NOT NULL DEFAULT '',
+	dashboard_id BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS dialog_states_expires_at_idx ON dialog_states (expires_at);
+
+ALTER TABLE dialog_states ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,4 @@
+-- Hasil validasi API key terakhir (lihat internal/handlers/keycheck.go).
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS key_status TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS key_checked_at TIMESTAMPTZ;
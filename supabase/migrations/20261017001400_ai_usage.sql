@@ -0,0 +1,26 @@
+-- Pemakaian AI per balasan (insert-only), dipakai untuk kuota key platform.
+CREATE TABLE IF NOT EXISTS ai_usage (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL,
+	customer_id BIGINT NOT NULL,
+	model TEXT NOT NULL,
+	shared_key BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ai_usage_owner_created_idx ON ai_usage (owner_id, created_at);
+
+ALTER TABLE ai_usage ENABLE ROW LEVEL SECURITY;
+
+-- Dipanggil lewat /rest/v1/rpc/usage_totals (SupabaseClient.SumUsage) supaya cek kuota di setiap
+-- pesan tidak memuat semua baris hari itu. Hanya service role yang boleh memanggilnya.
+CREATE OR REPLACE FUNCTION usage_totals(p_owner_id BIGINT, p_since TIMESTAMPTZ)
+RETURNS TABLE (replies BIGINT, shared_replies BIGINT)
+LANGUAGE sql STABLE
+AS $$
+	SELECT count(*), count(*) FILTER (WHERE shared_key)
+	FROM ai_usage
+	WHERE owner_id = p_owner_id AND created_at >= p_since
+$$;
+
+REVOKE EXECUTE ON FUNCTION usage_totals(BIGINT, TIMESTAMPTZ) FROM PUBLIC, anon, authenticated;
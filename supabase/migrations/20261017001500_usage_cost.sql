@@ -0,0 +1,25 @@
+-- Token dan biaya per balasan serta batas belanja owner (lihat internal/handlers/usage.go).
+ALTER TABLE ai_usage
+	ADD COLUMN IF NOT EXISTS prompt_tokens INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS completion_tokens INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS daily_spend_cap DOUBLE PRECISION NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS monthly_spend_cap DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Kolom hasil usage_totals bertambah, jadi fungsinya dibuat ulang (CREATE OR REPLACE tidak
+-- boleh mengubah tipe hasil).
+DROP FUNCTION IF EXISTS usage_totals(BIGINT, TIMESTAMPTZ);
+
+CREATE FUNCTION usage_totals(p_owner_id BIGINT, p_since TIMESTAMPTZ)
+RETURNS TABLE (replies BIGINT, shared_replies BIGINT, prompt_tokens BIGINT, completion_tokens BIGINT, cost DOUBLE PRECISION)
+LANGUAGE sql STABLE
+AS $$
+	SELECT count(*), count(*) FILTER (WHERE u.shared_key),
+		coalesce(sum(u.prompt_tokens), 0), coalesce(sum(u.completion_tokens), 0), coalesce(sum(u.cost), 0)
+	FROM ai_usage u
+	WHERE u.owner_id = p_owner_id AND u.created_at >= p_since
+$$;
+
+REVOKE EXECUTE ON FUNCTION usage_totals(BIGINT, TIMESTAMPTZ) FROM PUBLIC, anon, authenticated;
@@ -0,0 +1,12 @@
+-- Ringkasan berjalan per pelanggan (lihat internal/handlers/summary.go). Disimpan dengan upsert
+-- (resolution=merge-duplicates), jadi (owner_id, customer_id) harus primary key.
+CREATE TABLE IF NOT EXISTS conversation_summaries (
+	owner_id BIGINT NOT NULL,
+	customer_id BIGINT NOT NULL,
+	summary TEXT NOT NULL,
+	covered_count INTEGER NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (owner_id, customer_id)
+);
+
+ALTER TABLE conversation_summaries ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,17 @@
+-- Percakapan yang sedang ditangani owner secara manual (lihat internal/handlers/takeover.go).
+-- Disimpan dengan upsert (resolution=merge-duplicates), jadi (owner_id, customer_id) harus
+-- primary key.
+CREATE TABLE IF NOT EXISTS chat_pauses (
+	owner_id BIGINT NOT NULL,
+	customer_id BIGINT NOT NULL,
+	paused_until TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (owner_id, customer_id)
+);
+
+CREATE INDEX IF NOT EXISTS chat_pauses_owner_until_idx ON chat_pauses (owner_id, paused_until);
+
+ALTER TABLE chat_pauses ENABLE ROW LEVEL SECURITY;
+
+-- Chat pribadi owner dari koneksi bisnis, untuk mengenali balasan manual owner
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS business_user_chat_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Jam buka, hari libur dan perilaku di luar jam buka (lihat internal/handlers/hours.go).
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS business_hours TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS holidays TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS away_mode TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS away_prompt TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS away_message TEXT NOT NULL DEFAULT '';
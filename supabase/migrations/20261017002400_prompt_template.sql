@@ -0,0 +1,6 @@
+-- Profil owner dan variabel prompt buatan owner (lihat internal/handlers/template.go).
+-- prompt_vars boleh NULL: owner tanpa variabel dikirim sebagai null oleh bot.
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS first_name TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS last_name TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS prompt_vars JSONB;
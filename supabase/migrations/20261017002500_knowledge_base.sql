@@ -0,0 +1,24 @@
+-- Knowledge base per owner (lihat internal/handlers/knowledge.go). Dokumen disimpan lebih dulu
+-- dengan return=representation supaya ID-nya bisa dipakai oleh potongannya.
+CREATE TABLE IF NOT EXISTS knowledge_documents (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	chunk_count INTEGER NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS knowledge_documents_owner_idx ON knowledge_documents (owner_id);
+
+CREATE TABLE IF NOT EXISTS knowledge_chunks (
+	document_id BIGINT NOT NULL REFERENCES knowledge_documents (id) ON DELETE CASCADE,
+	owner_id BIGINT NOT NULL,
+	position INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	PRIMARY KEY (document_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS knowledge_chunks_owner_idx ON knowledge_chunks (owner_id);
+
+ALTER TABLE knowledge_documents ENABLE ROW LEVEL SECURITY;
+ALTER TABLE knowledge_chunks ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"tg-business-bot/internal/api"
+	"tg-business-bot/internal/dispatcher"
+	"time"
+)
+
+const webhookPath = "/webhook"
+
+// maxWebhookBody membatasi ukuran body update; update Telegram jauh lebih kecil dari ini.
+const maxWebhookBody = 1 << 20
+
+func runWebhook(ctx context.Context, cfg *Config, tg *api.TelegramClient, disp *dispatcher.Dispatcher) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(webhookPath, webhookHandler(cfg.WebhookSecret, disp.Submit))
+
+	if err := tg.SetWebhook(cfg.WebhookURL, cfg.WebhookSecret); err != nil {
+		log.Fatalf("Critical Error: Failed to register webhook %s: %v", cfg.WebhookURL, err)
+	}
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: mux}
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		// Beri waktu request yang sedang berjalan untuk selesai sebelum server ditutup
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: Webhook server shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("Bot Engine Started: Listening for webhook updates on :%s%s", cfg.Port, webhookPath)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Critical Error: Webhook server stopped: %v", err)
+	}
+
+	// ListenAndServe kembali sebelum Shutdown selesai; tunggu handler berhenti memanggil Submit
+	<-shutdownDone
+	log.Println("System: Shutting down, waiting for in-flight updates...")
+	disp.Stop()
+}
+
+// webhookHandler menerima update dari Telegram. Request tanpa secret token yang cocok ditolak
+// supaya endpoint publik ini tidak bisa dipakai untuk menyuntikkan update palsu.
+// dispatch dipanggil setelah update berhasil dibaca, sebelum respons 200 dikirim.
+func webhookHandler(secret string, dispatch func(api.Update)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			log.Printf("Webhook: Rejected request with invalid secret token from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update api.Update
+		body := http.MaxBytesReader(w, r.Body, maxWebhookBody)
+		if err := json.NewDecoder(body).Decode(&update); err != nil {
+			log.Printf("Webhook Error: Failed to decode update: %v", err)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// dispatch (Submit) hanya menunggu jika antrean worker penuh, jadi Telegram tetap cepat menerima 200
+		dispatch(update)
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tg-business-bot/internal/api"
+)
+
+func TestWebhookHandlerRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		want   int
+	}{
+		{"wrong method", http.MethodGet, "s3cret", `{"update_id":1}`, http.StatusMethodNotAllowed},
+		{"missing secret", http.MethodPost, "", `{"update_id":1}`, http.StatusUnauthorized},
+		{"wrong secret", http.MethodPost, "guess", `{"update_id":1}`, http.StatusUnauthorized},
+		{"invalid body", http.MethodPost, "s3cret", `{"update_id":`, http.StatusBadRequest},
+		{"oversized body", http.MethodPost, "s3cret", `{"update_id":1,"pad":"` + strings.Repeat("x", maxWebhookBody) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		h := webhookHandler("s3cret", func(api.Update) {
+			t.Errorf("%s: update dispatched", tt.name)
+		})
+		req := httptest.NewRequest(tt.method, webhookPath, strings.NewReader(tt.body))
+		if tt.secret != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.secret)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestWebhookHandlerDispatches(t *testing.T) {
+	var got []api.Update
+	h := webhookHandler("s3cret", func(u api.Update) { got = append(got, u) })
+
+	req := httptest.NewRequest(http.MethodPost, webhookPath, strings.NewReader(`{"update_id":42}`))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "s3cret")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if len(got) != 1 || got[0].UpdateID != 42 {
+		t.Fatalf("dispatched %+v, want update 42", got)
+	}
+}